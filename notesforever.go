@@ -1,13 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/floriankarydes/notesforever/pkg/config"
+	"github.com/floriankarydes/notesforever/pkg/export"
 	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/lfs"
+	"github.com/floriankarydes/notesforever/pkg/retention"
 	"github.com/floriankarydes/notesforever/pkg/service"
 	"github.com/floriankarydes/notesforever/pkg/sync"
 	"github.com/pkg/errors"
@@ -21,11 +29,25 @@ const (
 	gitUserDir   = "." + moduleName
 )
 
+// Exit status of a backup quarantined for deleting too much, distinct from the one of failures.
+const exitQuarantined = 2
+
+// Version of notesforever, set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
 
 	app := &cli.App{
-		Name:  "notesforever",
-		Usage: "backup macOS Notes to a Git repository",
+		Name:    "notesforever",
+		Version: version,
+		Usage:   "backup macOS Notes to a Git repository",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "path of the JSON configuration file",
+				EnvVars: []string{"NOTESFOREVER_CONFIG"},
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "init",
@@ -37,13 +59,23 @@ func main() {
 				Name:    "backup",
 				Aliases: []string{"b"},
 				Usage:   "backup notes",
-				Action:  Backup,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "dry-run", Usage: "print the changes a backup would commit, without changing anything"},
+					&cli.BoolFlag{Name: "force", Usage: "commit the backup even if a database fails its integrity check, or too much was deleted"},
+				},
+				Action: Backup,
 			},
 			{
 				Name:    "restore",
 				Aliases: []string{"r"},
 				Usage:   "restore notes",
-				Action:  Restore,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "device", Usage: "restore the last backup of another device"},
+					&cli.StringFlag{Name: "at", Usage: "restore a past backup: a snapshot, tag, commit or date"},
+					&cli.StringFlag{Name: "target", Usage: "restore into this directory instead, leaving Notes untouched"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "print the files a restore would overwrite, without changing anything"},
+				},
+				Action: Restore,
 			},
 			{
 				Name:    "configure",
@@ -51,6 +83,80 @@ func main() {
 				Usage:   "initialize backup file system & set up background service",
 				Action:  Configure,
 			},
+			{
+				Name:   "devices",
+				Usage:  "list devices backing up to the repository and their last backup",
+				Action: Devices,
+			},
+			{
+				Name:   "push",
+				Usage:  "push backups which could not be pushed yet",
+				Action: Push,
+			},
+			{
+				Name:   "status",
+				Usage:  "show backups which are not pushed yet",
+				Action: Status,
+			},
+			{
+				Name:  "snapshots",
+				Usage: "list backup snapshots",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "since", Usage: "only list snapshots since this date, e.g. 2026-10-01"},
+					&cli.StringFlag{Name: "until", Usage: "only list snapshots until this date"},
+				},
+				Action: Snapshots,
+				Subcommands: []*cli.Command{
+					{
+						Name:      "pin",
+						Usage:     "pin a snapshot so that pruning never removes it",
+						ArgsUsage: "<snapshot>",
+						Action:    Pin,
+					},
+					{
+						Name:      "unpin",
+						Usage:     "unpin a snapshot",
+						ArgsUsage: "<snapshot>",
+						Action:    Unpin,
+					},
+				},
+			},
+			{
+				Name:  "prune",
+				Usage: "thin the backup history with the retention policy",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "dry-run", Usage: "only report what would be pruned"},
+					&cli.BoolFlag{Name: "force-push", Usage: "overwrite the remote history with the pruned one"},
+				},
+				Action: Prune,
+			},
+			{
+				Name:  "verify",
+				Usage: "check the integrity of a backup",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "at", Usage: "verify a past backup: a snapshot, tag or commit"},
+				},
+				Action: Verify,
+			},
+			{
+				Name:  "export",
+				Usage: "write each note to a readable file, in directories mirroring the folders",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Usage: "format of the files: markdown or html", Value: string(export.Markdown)},
+					&cli.StringFlag{Name: "output", Usage: "directory the notes are written to, empty or not existing yet", Required: true},
+					&cli.StringFlag{Name: "at", Usage: "export a past backup: a snapshot, tag, commit or date"},
+				},
+				Action: Export,
+			},
+			{
+				Name:  "login",
+				Usage: "save a token for a remote host in the encrypted credential store",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "url", Usage: "remote URL the token is for", Value: "https://github.com"},
+					&cli.StringFlag{Name: "username", Usage: "username, if the remote requires one"},
+				},
+				Action: Login,
+			},
 		},
 	}
 
@@ -60,7 +166,7 @@ func main() {
 }
 
 func Init(c *cli.Context) error {
-	_, err := openSyncLink()
+	_, err := openSyncLink(c)
 	if err != nil {
 		return err
 	}
@@ -69,11 +175,23 @@ func Init(c *cli.Context) error {
 
 func Backup(c *cli.Context) error {
 	log.Println("starting backup...")
-	link, err := openSyncLink()
+	link, err := openSyncLink(c)
 	if err != nil {
 		return err
 	}
-	if err := link.Backup(); err != nil {
+	if c.Bool("dry-run") {
+		plan, err := link.PlanBackup()
+		if err != nil {
+			return err
+		}
+		fmt.Print(sync.FormatPlan(plan))
+		return nil
+	}
+	if err := link.Backup(sync.BackupOptions{Force: c.Bool("force")}); err != nil {
+		var quarantined *sync.QuarantinedError
+		if errors.As(err, &quarantined) {
+			return cli.Exit(fmt.Sprintf("%s; the branch is only kept in the local repository and is not pushed: inspect it, then run backup with --force to commit it", err.Error()), exitQuarantined)
+		}
 		return err
 	}
 	log.Println("backup completed")
@@ -82,14 +200,32 @@ func Backup(c *cli.Context) error {
 
 func Restore(c *cli.Context) error {
 	log.Println("restoring...")
-	link, err := openSyncLink()
+	link, err := openSyncLink(c)
 	if err != nil {
 		return err
 	}
-	if err := closeNotesApp(c.Context); err != nil {
-		return errors.Wrap(err, "failed to close Notes app")
+	opts := sync.RestoreOptions{Device: c.String("device"), Target: c.String("target")}
+	if at := c.String("at"); at != "" {
+		if t, err := parseDate(at, true); err == nil {
+			opts.Time = t
+		} else {
+			opts.Revision = at
+		}
+	}
+	if c.Bool("dry-run") {
+		plan, err := link.PlanRestore(opts)
+		if err != nil {
+			return err
+		}
+		fmt.Print(sync.FormatPlan(plan))
+		return nil
+	}
+	if opts.Target == "" {
+		if err := closeNotesApp(c.Context); err != nil {
+			return errors.Wrap(err, "failed to close Notes app")
+		}
 	}
-	if err := link.Restore(); err != nil {
+	if err := link.Restore(opts); err != nil {
 		return err
 	}
 	log.Println("restored")
@@ -98,7 +234,7 @@ func Restore(c *cli.Context) error {
 
 func Configure(c *cli.Context) error {
 	log.Println("configuring...")
-	if _, err := openSyncLink(); err != nil {
+	if _, err := openSyncLink(c); err != nil {
 		return err
 	}
 	if err := service.RunEverydayAt(0, moduleName, "backup"); err != nil {
@@ -108,18 +244,475 @@ func Configure(c *cli.Context) error {
 	return nil
 }
 
-func openSyncLink() (*sync.Link, error) {
+func Devices(c *cli.Context) error {
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	devices, err := link.Devices()
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Println("no device branch found; enable device branches in the configuration")
+	}
+	for _, d := range devices {
+		fmt.Printf("%s\t%s\n", d.ID, d.LastBackup.Local().Format(time.RFC1123))
+	}
+	return nil
+}
+
+func Push(c *cli.Context) error {
+	log.Println("pushing...")
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	results, err := link.Push()
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Printf("%s\t%s\tfailed: %s\n", res.Remote, res.URL, res.Err.Error())
+		} else {
+			fmt.Printf("%s\t%s\tok\n", res.Remote, res.URL)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("pushed")
+	return nil
+}
+
+func Status(c *cli.Context) error {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	opts, err := repoOptions(cfg)
+	if err != nil {
+		return err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	repo, err := git.Load(filepath.Join(homeDir, gitUserDir), opts)
+	if err != nil {
+		return err
+	}
+	for _, remote := range repo.Remotes() {
+		n, err := repo.Unpushed(remote)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d commit(s) not pushed\n", remote, n)
+	}
+	when, reason, err := sync.LastFailure(repo)
+	if err != nil {
+		return err
+	}
+	if !when.IsZero() {
+		fmt.Printf("last refused backup: %s: %s\n", when.Local().Format(time.RFC1123), reason)
+	}
+	return nil
+}
+
+func Snapshots(c *cli.Context) error {
+	var filter sync.SnapshotFilter
+	var err error
+	if c.String("since") != "" {
+		if filter.Since, err = parseDate(c.String("since"), false); err != nil {
+			return err
+		}
+	}
+	if c.String("until") != "" {
+		if filter.Until, err = parseDate(c.String("until"), true); err != nil {
+			return err
+		}
+	}
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	snapshots, err := link.Snapshots(filter)
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		var pinned string
+		if s.Pinned {
+			pinned = "\tpinned"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s%s\n", s.Name, s.When.Local().Format(time.RFC1123), s.Device, sync.FormatBytes(s.Size), s.Summary, pinned)
+	}
+	return nil
+}
+
+func Pin(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("expected one snapshot name")
+	}
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	if err := link.Pin(c.Args().First()); err != nil {
+		return err
+	}
+	log.Printf("pinned %s; the pin is pushed with the next backup", c.Args().First())
+	return nil
+}
+
+func Unpin(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("expected one snapshot name")
+	}
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	if err := link.Unpin(c.Args().First()); err != nil {
+		return err
+	}
+	log.Printf("unpinned %s", c.Args().First())
+	return nil
+}
+
+func Prune(c *cli.Context) error {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	policy, err := retentionPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	res, err := link.Prune(sync.PruneOptions{
+		Policy:    policy,
+		DryRun:    c.Bool("dry-run"),
+		ForcePush: c.Bool("force-push"),
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.Dropped == 0:
+		log.Printf("nothing to prune, %d backup(s) kept", res.Kept)
+	case c.Bool("dry-run"):
+		log.Printf("would keep %d backup(s) and drop %d", res.Kept, res.Dropped)
+	default:
+		log.Printf("kept %d backup(s) and dropped %d; former history is tagged %s", res.Kept, res.Dropped, res.SafetyTag)
+	}
+	return nil
+}
+
+func Verify(c *cli.Context) error {
+	log.Println("verifying...")
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	res, err := link.Verify(c.String("at"))
+	if err != nil {
+		return err
+	}
+	for _, p := range res.Problems {
+		fmt.Println(p)
+	}
+	if !res.Manifest {
+		log.Println("backup has no manifest; file hashes were not checked")
+	}
+	if len(res.Problems) > 0 {
+		return cli.Exit(fmt.Sprintf("backup %s is corrupt: %d problem(s) found", res.Hash, len(res.Problems)), 1)
+	}
+	if len(res.Unverified) > 0 {
+		for _, name := range res.Unverified {
+			fmt.Printf("%s: not verified, LFS object not in the local store\n", name)
+		}
+		log.Printf("backup %s partly verified: %d file(s), %d database(s); %d LFS file(s) not verified", res.Hash, res.Files, res.Databases, len(res.Unverified))
+		return nil
+	}
+	log.Printf("backup %s verified: %d file(s), %d database(s)", res.Hash, res.Files, res.Databases)
+	return nil
+}
+
+func Export(c *cli.Context) error {
+	log.Println("exporting...")
+	format, err := export.ParseFormat(c.String("format"))
+	if err != nil {
+		return err
+	}
+	link, err := openSyncLink(c)
+	if err != nil {
+		return err
+	}
+	opts := sync.ExportOptions{Format: format, Target: c.String("output")}
+	if at := c.String("at"); at != "" {
+		if t, err := parseDate(at, true); err == nil {
+			opts.Time = t
+		} else {
+			opts.Revision = at
+		}
+	}
+	n, err := link.Export(opts)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d note(s) exported to %s", n, opts.Target)
+	return nil
+}
+
+func Login(c *cli.Context) error {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	store := credentialStore(cfg)
+	fmt.Printf("token for %s: ", c.String("url"))
+	token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return errors.Wrap(err, "failed to read token")
+	}
+	cred := git.Credential{Username: c.String("username"), Password: strings.TrimSpace(token)}
+	if err := store.Put(c.String("url"), cred); err != nil {
+		return err
+	}
+	log.Printf("token saved in %s", store.Path)
+	return nil
+}
+
+func openSyncLink(c *cli.Context) (*sync.Link, error) {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return newSyncLink(cfg, c.Bool("dry-run"))
+}
+
+// Link between the notes and the repository. A dry run loads the repository without pulling, as pulling may merge,
+// reset or clone.
+func newSyncLink(cfg *config.Config, dryRun bool) (*sync.Link, error) {
+	repo, err := openRepo(cfg, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	deviceID, err := deviceID(cfg)
+	if err != nil {
+		return nil, err
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
 	}
-	gitDir := filepath.Join(homeDir, gitUserDir)
+	tracker, err := lfsTracker(cfg, repo)
+	if err != nil {
+		return nil, err
+	}
+	var format export.Format
+	if cfg.Backup.Export != "" {
+		if format, err = export.ParseFormat(cfg.Backup.Export); err != nil {
+			return nil, errors.Wrap(err, "invalid backup export")
+		}
+	}
 	syncDir := filepath.Join(homeDir, notesUserDir)
-	repo, err := git.Open(gitDir, "")
+	return sync.New(repo, syncDir, sync.Options{
+		Version:  version,
+		DeviceID: deviceID,
+		LFS:      tracker,
+		Checksum: cfg.Backup.Checksum,
+		Guard:    sync.Guard(cfg.Backup.Deletion),
+		Export:   format,
+	})
+}
+
+// LFS tracker of the repository, or nil if LFS is disabled.
+func lfsTracker(cfg *config.Config, repo *git.Repo) (*lfs.Tracker, error) {
+	if !cfg.LFS.Enabled {
+		return nil, nil
+	}
+	endpoint := cfg.LFS.URL
+	if endpoint == "" {
+		var err error
+		if endpoint, err = lfs.Endpoint(repo.URL()); err != nil {
+			return nil, err
+		}
+	}
+	return &lfs.Tracker{
+		Filter: lfs.Filter{Threshold: cfg.LFS.Threshold, Patterns: cfg.LFS.Patterns},
+		Store:  lfs.NewStore(filepath.Join(repo.Dir(), ".git", "lfs", "objects")),
+		Client: lfs.NewClient(endpoint, func() (string, string, error) {
+			return repo.BasicAuth(endpoint)
+		}),
+		Uploaded: filepath.Join(repo.Dir(), ".git", "lfs", "uploaded"),
+	}, nil
+}
+
+// ID of this device if devices back up to their own branch, else empty.
+func deviceID(cfg *config.Config) (string, error) {
+	if !cfg.Device.Branches {
+		return "", nil
+	}
+	if cfg.Device.ID != "" {
+		return cfg.Device.ID, nil
+	}
+	return sync.DefaultDeviceID()
+}
+
+// Open the repository, pulling it unless load is true.
+func openRepo(cfg *config.Config, load bool) (*git.Repo, error) {
+	opts, err := repoOptions(cfg)
 	if err != nil {
 		return nil, err
 	}
-	return sync.New(repo, syncDir)
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	gitDir := filepath.Join(homeDir, gitUserDir)
+	if load {
+		return git.Load(gitDir, opts)
+	}
+	return git.Open(gitDir, opts)
+}
+
+func repoOptions(cfg *config.Config) (git.Options, error) {
+	creds, err := credentialProvider(cfg)
+	if err != nil {
+		return git.Options{}, err
+	}
+	retry := git.RetryOptions{Attempts: cfg.Push.Attempts}
+	if cfg.Push.Backoff != "" {
+		if retry.Backoff, err = time.ParseDuration(cfg.Push.Backoff); err != nil {
+			return git.Options{}, errors.Wrap(err, "invalid push backoff")
+		}
+	}
+	deviceID, err := deviceID(cfg)
+	if err != nil {
+		return git.Options{}, err
+	}
+	var branch string
+	if deviceID != "" {
+		branch = sync.DeviceBranch(deviceID)
+	}
+	var mirrors []git.Remote
+	for _, m := range cfg.Mirrors {
+		mirrors = append(mirrors, git.Remote(m))
+	}
+	return git.Options{
+		URL:         cfg.URL,
+		Credentials: creds,
+		SSH: git.SSHOptions{
+			User:       cfg.SSH.User,
+			KeyFile:    cfg.SSH.KeyFile,
+			Passphrase: os.Getenv(cfg.SSH.PassphraseEnv),
+			KnownHosts: cfg.SSH.KnownHosts,
+		},
+		Host: git.HostOptions{
+			Provider: cfg.Host.Provider,
+			URL:      cfg.Host.URL,
+			Owner:    cfg.Host.Owner,
+		},
+		Retry:      retry,
+		Mirrors:    mirrors,
+		Branch:     branch,
+		Tags:       sync.TagPrefixes(deviceID),
+		Divergence: cfg.Divergence,
+		Author:     git.Identity(cfg.Author),
+		Committer:  git.Identity(cfg.Committer),
+		Signing: git.SigningOptions{
+			Format:     cfg.Signing.Format,
+			KeyFile:    cfg.Signing.KeyFile,
+			Passphrase: os.Getenv(cfg.Signing.PassphraseEnv),
+		},
+	}, nil
+}
+
+// Parse a date, e.g. "2026-10-16", "2026-10-16T08:30" or RFC 3339, in local time. A date without time stands for the
+// start of the day, or its end if endOfDay is true.
+func parseDate(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, errors.Errorf("invalid date %q, expected e.g. 2026-10-16 or 2026-10-16T08:30", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// Retention policy of the configuration, on top of the default one.
+func retentionPolicy(cfg *config.Config) (retention.Policy, error) {
+	policy := retention.Default()
+	for _, period := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{cfg.Retention.All, &policy.All},
+		{cfg.Retention.Daily, &policy.Daily},
+		{cfg.Retention.Weekly, &policy.Weekly},
+		{cfg.Retention.Monthly, &policy.Monthly},
+	} {
+		if period.value == "" {
+			continue
+		}
+		d, err := retention.ParseDuration(period.value)
+		if err != nil {
+			return policy, err
+		}
+		*period.dst = d
+	}
+	return policy, nil
+}
+
+func loadConfig(c *cli.Context) (*config.Config, error) {
+	path := c.String("config")
+	if path == "" {
+		dir, err := config.Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "config.json")
+	}
+	return config.Load(path)
+}
+
+// Chain credential providers in the configured order.
+func credentialProvider(cfg *config.Config) (git.CredentialProvider, error) {
+	var chain git.Chain
+	for _, name := range cfg.Credentials.Providers {
+		switch name {
+		case "env":
+			chain = append(chain, &git.EnvProvider{Vars: cfg.Credentials.EnvVars})
+		case "file":
+			chain = append(chain, &git.FileProvider{Path: cfg.Credentials.TokenFile})
+		case "store":
+			chain = append(chain, credentialStore(cfg))
+		case "helper":
+			chain = append(chain, &git.HelperProvider{})
+		case "keychain":
+			chain = append(chain, &git.KeychainProvider{})
+		default:
+			return nil, errors.Errorf("unknown credential provider %q", name)
+		}
+	}
+	return chain, nil
+}
+
+func credentialStore(cfg *config.Config) *git.StoreProvider {
+	return &git.StoreProvider{
+		Path:       cfg.Credentials.StoreFile,
+		Passphrase: os.Getenv(cfg.Credentials.StorePassphraseEnv),
+	}
 }
 
 const notesAppName = "Notes"
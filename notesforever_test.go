@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/floriankarydes/notesforever/pkg/config"
+	"github.com/floriankarydes/notesforever/pkg/git"
+	gogit "github.com/go-git/go-git/v5"
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// Commit a file to r, and push it if push is true.
+func commitFile(t *testing.T, r *git.Repo, name string, push bool) {
+	t.Helper()
+	assert.NilError(t, os.WriteFile(filepath.Join(r.Dir(), name), []byte(name), 0644))
+	_, err := r.Stage()
+	assert.NilError(t, err)
+	_, err = r.Commit(name)
+	assert.NilError(t, err)
+	if push {
+		results, err := r.Push()
+		assert.NilError(t, err)
+		for _, res := range results {
+			assert.NilError(t, res.Err)
+		}
+	}
+}
+
+// References of the repository at dir, by name.
+func refsOf(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	gitRepo, err := gogit.PlainOpen(dir)
+	assert.NilError(t, err)
+	iter, err := gitRepo.References()
+	assert.NilError(t, err)
+	refs := map[string]string{}
+	assert.NilError(t, iter.ForEach(func(ref *plumbing.Reference) error {
+		refs[ref.Name().String()] = ref.Hash().String()
+		return nil
+	}))
+	return refs
+}
+
+func TestDryRunDoesNotPull(t *testing.T) {
+	for _, divergence := range []string{git.DivergeLocal, git.DivergeRemote} {
+		for _, diverged := range []bool{false, true} {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			assert.NilError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = test\n\temail = test@example.com\n"), 0644))
+			assert.NilError(t, os.MkdirAll(filepath.Join(home, notesUserDir), 0755))
+			bare := filepath.Join(t.TempDir(), "remote.git")
+			_, err := gogit.PlainInit(bare, true)
+			assert.NilError(t, err)
+			seedDir := t.TempDir()
+			seedRepo, err := gogit.PlainInit(seedDir, false)
+			assert.NilError(t, err)
+			_, err = seedRepo.CreateRemote(&gogitconfig.RemoteConfig{Name: gogit.DefaultRemoteName, URLs: []string{bare}})
+			assert.NilError(t, err)
+			seed, err := git.Load(seedDir, git.Options{URL: bare})
+			assert.NilError(t, err)
+			commitFile(t, seed, "README.md", true)
+
+			local, err := git.Open(filepath.Join(home, gitUserDir), git.Options{URL: bare})
+			assert.NilError(t, err)
+			// The remote is ahead, or has diverged from the local history.
+			commitFile(t, seed, "remote.txt", true)
+			if diverged {
+				commitFile(t, local, "local.txt", false)
+			}
+			before := refsOf(t, local.Dir())
+
+			cfg := config.Default()
+			cfg.URL = bare
+			cfg.Divergence = divergence
+			cfg.Credentials.Providers = nil
+			link, err := newSyncLink(cfg, true)
+			assert.NilError(t, err)
+			_, err = link.PlanBackup()
+			assert.NilError(t, err)
+			assert.Check(t, is.DeepEqual(refsOf(t, local.Dir()), before), "%s, diverged: %t", divergence, diverged)
+
+			// Unlike a real run.
+			_, err = newSyncLink(cfg, false)
+			assert.NilError(t, err)
+			assert.Check(t, refsOf(t, local.Dir())["refs/heads/master"] != before["refs/heads/master"])
+		}
+	}
+}
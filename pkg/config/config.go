@@ -0,0 +1,207 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Config of notesforever, read from a JSON file.
+type Config struct {
+	// URL of the backup repository. If empty, a GitHub repository is created.
+	URL string `json:"url,omitempty"`
+	// Credentials used to authenticate against the backup repository.
+	Credentials Credentials `json:"credentials"`
+	// SSH authentication, used when URL is an SSH remote.
+	SSH SSH `json:"ssh"`
+	// Host where the repository is found or created.
+	Host Host `json:"host"`
+	// Push retries while the remote is unreachable.
+	Push Push `json:"push"`
+	// Mirrors pushed to in addition to URL. Only a failure to push to URL is fatal.
+	Mirrors []Remote `json:"mirrors,omitempty"`
+	// Divergence strategy when local and remote histories have diverged: "local" (default) keeps the local backup
+	// with a merge commit, "remote" resets to the remote history, "abort" fails.
+	Divergence string `json:"divergence,omitempty"`
+	// Device backs up each device to its own branch.
+	Device Device `json:"device"`
+	// Author of backup commits. Defaults to the user of the Git configuration.
+	Author Identity `json:"author"`
+	// Committer of backup commits. Defaults to Author.
+	Committer Identity `json:"committer"`
+	// Signing of backup commits.
+	Signing Signing `json:"signing"`
+	// LFS stores large attachments with Git LFS.
+	LFS LFS `json:"lfs"`
+	// Retention policy applied by the prune command.
+	Retention Retention `json:"retention"`
+	// Backup configures how notes are copied to the repository.
+	Backup Backup `json:"backup"`
+}
+
+// Credentials lists the credential providers to try, in order.
+type Credentials struct {
+	// Providers to try, in order, among "env", "file", "store", "helper" and "keychain".
+	Providers []string `json:"providers,omitempty"`
+	// EnvVars holding a token, for the "env" provider.
+	EnvVars []string `json:"envVars,omitempty"`
+	// TokenFile holding a token, for the "file" provider.
+	TokenFile string `json:"tokenFile,omitempty"`
+	// StoreFile is the encrypted credential store, for the "store" provider.
+	StoreFile string `json:"storeFile,omitempty"`
+	// StorePassphraseEnv is the environment variable holding the store passphrase.
+	StorePassphraseEnv string `json:"storePassphraseEnv,omitempty"`
+}
+
+// SSH configures authentication against SSH remotes.
+type SSH struct {
+	// User to connect as. Defaults to the user of the URL, else "git".
+	User string `json:"user,omitempty"`
+	// KeyFile is a private key, e.g. a deploy key. If empty, ssh-agent is used.
+	KeyFile string `json:"keyFile,omitempty"`
+	// PassphraseEnv is the environment variable holding the passphrase of KeyFile.
+	PassphraseEnv string `json:"passphraseEnv,omitempty"`
+	// KnownHosts files used to verify the server key. Defaults to the OpenSSH ones.
+	KnownHosts []string `json:"knownHosts,omitempty"`
+}
+
+// Host selects the hosting provider of the backup repository.
+type Host struct {
+	// Provider among "github" (default), "gitlab", "gitea" and "plain". A plain remote never calls an API.
+	Provider string `json:"provider,omitempty"`
+	// URL of the provider instance; the API root for GitHub Enterprise. Defaults to the public instance.
+	URL string `json:"url,omitempty"`
+	// Owner (user, organization or group) of the repository to create. Defaults to the authenticated user.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Push configures the exponential backoff of pushes.
+type Push struct {
+	// Attempts before giving up; pending commits are pushed on the next run.
+	Attempts int `json:"attempts,omitempty"`
+	// Backoff before the second attempt, e.g. "1s", doubled after each failure.
+	Backoff string `json:"backoff,omitempty"`
+}
+
+// Remote is a named mirror of the backup repository.
+type Remote struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Device configures per-device branches, for several Macs backing up to one repository.
+type Device struct {
+	// Branches enables one branch per device, named "device/<id>".
+	Branches bool `json:"branches,omitempty"`
+	// ID of this device. Defaults to the hostname.
+	ID string `json:"id,omitempty"`
+}
+
+// Identity of the author or committer of commits.
+type Identity struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Signing configures the signature of commits.
+type Signing struct {
+	// Format of the signature, "openpgp" or "ssh". Commits are not signed if empty.
+	Format string `json:"format,omitempty"`
+	// KeyFile is an armored OpenPGP private key, or an OpenSSH private key.
+	KeyFile string `json:"keyFile,omitempty"`
+	// PassphraseEnv is the environment variable holding the passphrase of KeyFile.
+	PassphraseEnv string `json:"passphraseEnv,omitempty"`
+}
+
+// LFS configures the files stored with Git LFS.
+type LFS struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Threshold in bytes above which files are stored with LFS. Defaults to 10 MB.
+	Threshold int64 `json:"threshold,omitempty"`
+	// Patterns of files stored with LFS whatever their size, e.g. "*.pdf".
+	Patterns []string `json:"patterns,omitempty"`
+	// URL of the LFS server. Defaults to the one of the backup repository, as for git-lfs.
+	URL string `json:"url,omitempty"`
+}
+
+// Retention is a grandfather-father-son policy. Periods are Go durations, days ("30d"), weeks ("4w"), years ("1y")
+// or "forever"; they default to 7 days, 30 days, 1 year and forever.
+type Retention struct {
+	// All backups younger than All are kept.
+	All string `json:"all,omitempty"`
+	// Daily keeps the last backup of each day younger than Daily.
+	Daily string `json:"daily,omitempty"`
+	// Weekly keeps the last backup of each week younger than Weekly.
+	Weekly string `json:"weekly,omitempty"`
+	// Monthly keeps the last backup of each month younger than Monthly.
+	Monthly string `json:"monthly,omitempty"`
+}
+
+// Backup configures how notes are copied to the repository.
+type Backup struct {
+	// Checksum compares the content of files with the same size and modification time, instead of assuming they did
+	// not change.
+	Checksum bool `json:"checksum,omitempty"`
+	// Deletion quarantines backups deleting too much since the previous one.
+	Deletion Deletion `json:"deletion"`
+	// Export writes the notes to a notes directory next to the backup, one readable file per note, in this format:
+	// "markdown" or "html". Notes are not exported if empty.
+	Export string `json:"export,omitempty"`
+}
+
+// Deletion sets the largest drops from the previous backup, as fractions, e.g. 0.25 for 25%, above which a backup is
+// committed to a quarantine branch instead. Zero disables a check.
+type Deletion struct {
+	// Notes is the drop of the number of notes. Defaults to 10%.
+	Notes float64 `json:"notes"`
+	// Files is the drop of the number of files. Defaults to 25%.
+	Files float64 `json:"files"`
+	// Bytes is the drop of the total size of files. Defaults to 25%.
+	Bytes float64 `json:"bytes"`
+}
+
+// Default configuration, used when no configuration file exists.
+func Default() *Config {
+	return &Config{
+		Credentials: Credentials{
+			Providers:          []string{"env", "file", "store", "helper", "keychain"},
+			EnvVars:            []string{"GITHUB_AUTH_TOKEN"},
+			StorePassphraseEnv: "NOTESFOREVER_STORE_PASSPHRASE",
+		},
+		LFS: LFS{
+			Threshold: 10 * 1000 * 1000,
+		},
+		Backup: Backup{
+			Deletion: Deletion{Notes: 0.1, Files: 0.25, Bytes: 0.25},
+		},
+	}
+}
+
+// Dir returns the default directory of the configuration file.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "notesforever"), nil
+}
+
+// Load the configuration file at path on top of the default configuration. A missing file is not an error.
+func Load(path string) (*Config, error) {
+	c := Default()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read configuration")
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse configuration %s", path)
+		}
+	}
+	if c.Credentials.StoreFile == "" {
+		c.Credentials.StoreFile = filepath.Join(filepath.Dir(path), "credentials.enc")
+	}
+	return c, nil
+}
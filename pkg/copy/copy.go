@@ -198,15 +198,15 @@ func DirCopy(srcDir, dstDir string, copyMode Mode, copyOpaqueXattrs bool) error
 		if f.IsDir() {
 			dirsToSetMtimes.PushFront(&dirMtimeInfo{dstPath: &dstPath, stat: stat})
 		} else if !isSymlink {
-			aTime := time.Unix(stat.Atimespec.Unix())
-			mTime := time.Unix(stat.Mtimespec.Unix())
+			atime, mtime := statTimes(stat)
+			aTime := time.Unix(atime.Unix())
+			mTime := time.Unix(mtime.Unix())
 			if err := system.Chtimes(dstPath, aTime, mTime); err != nil {
 				return err
 			}
 		} else {
-			ats := unix.Timespec{Sec: stat.Atimespec.Sec, Nsec: stat.Atimespec.Nsec}
-			mts := unix.Timespec{Sec: stat.Mtimespec.Sec, Nsec: stat.Mtimespec.Nsec}
-			ts := []unix.Timespec{ats, mts}
+			atime, mtime := statTimes(stat)
+			ts := []unix.Timespec{unix.NsecToTimespec(atime.Nano()), unix.NsecToTimespec(mtime.Nano())}
 			unix.UtimesNano(dstPath, ts) // Ignore error for macOS.
 		}
 		return nil
@@ -216,9 +216,8 @@ func DirCopy(srcDir, dstDir string, copyMode Mode, copyOpaqueXattrs bool) error
 	}
 	for e := dirsToSetMtimes.Front(); e != nil; e = e.Next() {
 		mtimeInfo := e.Value.(*dirMtimeInfo)
-		ats := unix.Timespec{Sec: mtimeInfo.stat.Atimespec.Sec, Nsec: mtimeInfo.stat.Atimespec.Nsec}
-		mts := unix.Timespec{Sec: mtimeInfo.stat.Mtimespec.Sec, Nsec: mtimeInfo.stat.Mtimespec.Nsec}
-		ts := []unix.Timespec{ats, mts}
+		atime, mtime := statTimes(mtimeInfo.stat)
+		ts := []unix.Timespec{unix.NsecToTimespec(atime.Nano()), unix.NsecToTimespec(mtime.Nano())}
 		unix.UtimesNano(*mtimeInfo.dstPath, ts) // Ignore error for macOS.
 	}
 
@@ -0,0 +1,81 @@
+package copy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pkg/xattr"
+	"golang.org/x/sys/unix"
+)
+
+// Metadata of a file which is not part of its content.
+type Metadata struct {
+	// Mode holds the type, permission and special bits.
+	Mode         os.FileMode
+	UID, GID     int
+	Atime, Mtime time.Time
+	// Xattrs are the extended attributes, by name.
+	Xattrs map[string][]byte
+}
+
+// ReadMetadata reads the metadata of the file at path, without following symlinks.
+func ReadMetadata(path string) (*Metadata, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("unable to get raw syscall.Stat_t data for %s", path)
+	}
+	atime, mtime := statTimes(stat)
+	md := &Metadata{
+		Mode:  info.Mode(),
+		UID:   int(stat.Uid),
+		GID:   int(stat.Gid),
+		Atime: time.Unix(atime.Unix()),
+		Mtime: time.Unix(mtime.Unix()),
+	}
+	names, err := xattr.LList(path)
+	if err != nil && !errors.Is(err, syscall.EOPNOTSUPP) {
+		return nil, err
+	}
+	for _, name := range names {
+		data, err := xattr.LGet(path, name)
+		if err != nil {
+			return nil, err
+		}
+		if md.Xattrs == nil {
+			md.Xattrs = map[string][]byte{}
+		}
+		md.Xattrs[name] = data
+	}
+	return md, nil
+}
+
+// ApplyMetadata sets the ownership, mode, extended attributes and times of the file at path, without following
+// symlinks. Ownership is left as is when the process is not allowed to change it.
+func ApplyMetadata(path string, md *Metadata) error {
+	if err := os.Lchown(path, md.UID, md.GID); err != nil && !errors.Is(err, syscall.EPERM) {
+		return err
+	}
+	for name, data := range md.Xattrs {
+		if err := xattr.LSet(path, name, data); err != nil {
+			if errors.Is(err, syscall.EOPNOTSUPP) {
+				continue
+			}
+			return err
+		}
+	}
+	// There is no LChmod, so ignore mode for symlink. This must happen after chown, as that can modify the file mode.
+	if md.Mode&os.ModeSymlink == 0 {
+		if err := os.Chmod(path, md.Mode); err != nil {
+			return err
+		}
+	}
+	ts := []unix.Timespec{unix.NsecToTimespec(md.Atime.UnixNano()), unix.NsecToTimespec(md.Mtime.UnixNano())}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
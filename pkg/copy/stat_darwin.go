@@ -0,0 +1,8 @@
+package copy
+
+import "syscall"
+
+// Access and modification times of a stat.
+func statTimes(stat *syscall.Stat_t) (atime, mtime syscall.Timespec) {
+	return stat.Atimespec, stat.Mtimespec
+}
@@ -0,0 +1,348 @@
+// Package export writes the notes of a Notes database as readable files, one per note, in directories mirroring the
+// folders of each account.
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/floriankarydes/notesforever/pkg/notestore"
+	"github.com/pkg/errors"
+)
+
+// Format of exported notes.
+type Format string
+
+// Formats of exported notes.
+const (
+	Markdown Format = "markdown"
+	// HTML pages are standalone, with an index page per folder.
+	HTML Format = "html"
+)
+
+// Longest name of exported files and directories, in runes, before their extension.
+const maxNameLength = 100
+
+// AttachmentsSuffix ends the name of the directory holding the attachments of a note, after the name of the note.
+const AttachmentsSuffix = " attachments"
+
+// Largest attachment embedded in an HTML page, rather than copied next to it.
+var maxEmbeddedSize int64 = 10 * 1000 * 1000
+
+// Permissions of exported directories and files.
+const (
+	dirPerm  = 0755
+	filePerm = 0644
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Markdown, HTML:
+		return f, nil
+	default:
+		return "", errors.Errorf("unknown export format %q", s)
+	}
+}
+
+// Ext is the extension of the files of exported notes, e.g. ".md".
+func (f Format) Ext() string {
+	if f == HTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+func (f Format) render(n *note) []byte {
+	if f == HTML {
+		return renderHTML(n)
+	}
+	return renderMarkdown(n)
+}
+
+// Note to render, with its content and the files of its attachments.
+type note struct {
+	notestore.Note
+	folder notestore.Folder
+	// document is nil for a locked note, or a note whose content cannot be decoded.
+	document *notestore.Document
+	// attachments of the note, by identifier.
+	attachments map[string]notestore.Attachment
+	// files of the attachments copied next to the note, as slash-separated paths relative to it, by identifier.
+	files map[string]string
+	// tables of the table attachments, by identifier.
+	tables map[string]*notestore.Table
+	// embedded attachments of HTML pages, as data URLs, by identifier.
+	embedded map[string]string
+}
+
+// Export the notes of the Notes database at db into dst, which must be empty or not exist. Attachments are copied next
+// to their note from container, the directory of the Notes app the database belongs to. It returns the number of notes
+// written.
+func Export(db, container, dst string, format Format) (int, error) {
+	store, err := notestore.Open(db)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+	accounts, err := store.Accounts()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read accounts")
+	}
+	folders, err := store.Folders()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read folders")
+	}
+	notes, err := store.Notes()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read notes")
+	}
+
+	accountByID := map[int64]notestore.Account{}
+	for _, a := range accounts {
+		accountByID[a.ID] = a
+	}
+	folderByID := map[int64]notestore.Folder{}
+	for _, f := range folders {
+		folderByID[f.ID] = f
+	}
+	// Names taken in each directory, lowercase as the file system of macOS ignores case.
+	taken := map[string]bool{}
+	indexes := indexes{}
+	count := 0
+	for _, n := range notes {
+		folder, ok := folderByID[n.FolderID]
+		if !ok {
+			continue
+		}
+		account := accountByID[folder.AccountID]
+		dir := indexes.add(dst, "", "Notes")
+		dir = indexes.add(dir, safeName(account.Name), account.Name)
+		for _, title := range folder.Path {
+			dir = indexes.add(dir, safeName(title), title)
+		}
+		if err := os.MkdirAll(dir, dirPerm); err != nil {
+			return count, err
+		}
+		if format == HTML {
+			// Leave the name of the index page to it.
+			taken[strings.ToLower(filepath.Join(dir, strings.TrimSuffix(IndexFilename, format.Ext())))] = true
+		}
+		name := uniqueName(dir, safeName(n.Title), taken)
+
+		e := &note{Note: n, folder: folder, attachments: map[string]notestore.Attachment{}, files: map[string]string{},
+			tables: map[string]*notestore.Table{}, embedded: map[string]string{}}
+		if !n.Locked {
+			if e.document, err = store.Document(n.ID); err != nil {
+				log.Printf("%s; exporting note %s without its content", err.Error(), n.Identifier)
+			}
+		}
+		for _, a := range n.Attachments {
+			if a.TypeUTI != notestore.TableTypeUTI {
+				continue
+			}
+			if e.tables[a.Identifier], err = store.Table(a.ID); err != nil {
+				log.Printf("%s; exporting note %s without it", err.Error(), n.Identifier)
+			}
+		}
+		if err := copyAttachments(e, container, account.Identifier, dir, name, format); err != nil {
+			return count, errors.Wrapf(err, "failed to copy attachments of note %s", n.Identifier)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+format.Ext()), format.render(e), filePerm); err != nil {
+			return count, err
+		}
+		indexes[dir].notes = append(indexes[dir].notes, indexEntry{name: name + format.Ext(), note: n})
+		count++
+	}
+	if format == HTML {
+		if err := indexes.write(); err != nil {
+			return count, errors.Wrap(err, "failed to write indexes")
+		}
+	}
+	return count, nil
+}
+
+// Copy the files of the attachments of n from the Notes container into a directory next to the note, named after the
+// note, and record their paths in n. HTML pages embed the files up to maxEmbeddedSize instead, so that they stand
+// alone.
+func copyAttachments(n *note, container, account, dir, name string, format Format) error {
+	attachmentsDir := name + AttachmentsSuffix
+	taken := map[string]bool{}
+	for _, a := range n.Attachments {
+		n.attachments[a.Identifier] = a
+		src := mediaFile(container, account, a)
+		if src == "" {
+			continue
+		}
+		filename := a.Filename
+		if filename == "" {
+			filename = filepath.Base(src)
+		}
+		if format == HTML {
+			if info, err := os.Stat(src); err == nil && info.Size() <= maxEmbeddedSize {
+				data, err := os.ReadFile(src)
+				if err != nil {
+					return err
+				}
+				n.files[a.Identifier] = filename
+				n.embedded[a.Identifier] = dataURL(filename, data)
+				continue
+			}
+		}
+		ext := filepath.Ext(filename)
+		filename = uniqueName(filepath.Join(dir, attachmentsDir), safeName(strings.TrimSuffix(filename, ext)), taken) + ext
+		if err := copyFile(src, filepath.Join(dir, attachmentsDir, filename)); err != nil {
+			return err
+		}
+		n.files[a.Identifier] = attachmentsDir + "/" + filename
+	}
+	return nil
+}
+
+// Data URL of the content of the file name.
+func dataURL(name string, data []byte) string {
+	typ := mime.TypeByExtension(filepath.Ext(name))
+	if typ == "" {
+		typ = http.DetectContentType(data)
+	}
+	return "data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// Path of the file of attachment a in the Notes container, or an empty string if it has none. Media are stored in a
+// directory named after their identifier, in the directory of their account, or at the root of the container for
+// older versions of Notes.
+func mediaFile(container, account string, a notestore.Attachment) string {
+	if a.MediaIdentifier == "" {
+		return ""
+	}
+	for _, dir := range []string{
+		filepath.Join(container, "Accounts", account, "Media", a.MediaIdentifier),
+		filepath.Join(container, "Media", a.MediaIdentifier),
+	} {
+		var found, first string
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if found != "" {
+				return filepath.SkipDir
+			}
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			if first == "" {
+				first = path
+			}
+			if d.Name() == a.Filename {
+				found = path
+			}
+			return nil
+		})
+		if found != "" {
+			return found
+		}
+		if first != "" {
+			return first
+		}
+	}
+	return ""
+}
+
+// Name of a file or directory with the title s, without the characters file systems forbid.
+func safeName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\' || r == ':':
+			return '-'
+		case unicode.IsControl(r):
+			return ' '
+		}
+		return r
+	}, s)
+	s = strings.TrimLeft(strings.TrimSpace(s), ".")
+	if runes := []rune(s); len(runes) > maxNameLength {
+		s = strings.TrimSpace(string(runes[:maxNameLength]))
+	}
+	if s == "" {
+		return "Untitled"
+	}
+	return s
+}
+
+// Name, not taken yet in dir, derived from name, and mark it as taken.
+func uniqueName(dir, name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[strings.ToLower(filepath.Join(dir, unique))]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	taken[strings.ToLower(filepath.Join(dir, unique))] = true
+	return unique
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), dirPerm); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Paragraph of a document: a line of text, with the attributes of the run ending it.
+type paragraph struct {
+	notestore.Paragraph
+	// Runs of the paragraph, without the newline ending it.
+	Runs []notestore.Run
+}
+
+// Text of the paragraph, without attributes.
+func (p paragraph) Text() string {
+	var b strings.Builder
+	for _, r := range p.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+// Split a document into paragraphs.
+func paragraphs(doc *notestore.Document) []paragraph {
+	var result []paragraph
+	var current paragraph
+	for _, r := range doc.Runs {
+		lines := strings.Split(r.Text, "\n")
+		for i, line := range lines {
+			if line != "" {
+				part := r
+				part.Text = line
+				current.Runs = append(current.Runs, part)
+			}
+			if i < len(lines)-1 {
+				current.Paragraph = r.Paragraph
+				result = append(result, current)
+				current = paragraph{}
+			}
+		}
+		current.Paragraph = r.Paragraph
+	}
+	if len(current.Runs) > 0 {
+		result = append(result, current)
+	}
+	return result
+}
@@ -0,0 +1,280 @@
+package export
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/floriankarydes/notesforever/pkg/notestore"
+	_ "github.com/floriankarydes/notesforever/pkg/sqlite"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// Notes database with the content of testdata/formatted.pb.gz in the cake note.
+var fixture = filepath.Join("..", "notestore", "testdata", "modern.sqlite")
+
+// Notes container holding the photo of the cake note, as Notes stores it.
+func container(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	media := filepath.Join(dir, "Accounts", "A1B2C3D4-ICLOUD", "Media", "MEDIA-PHOTO", "1_0F7A")
+	assert.NilError(t, os.MkdirAll(media, 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(media, "cake.jpeg"), []byte("JPEG"), 0644))
+	return dir
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	assert.NilError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestExportMarkdown(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "notes")
+	n, err := Export(fixture, container(t), dst, Markdown)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, 4))
+	assert.Check(t, is.DeepEqual(listFiles(t, dst), []string{
+		"On My Mac/Notes/Secret.md",
+		"iCloud/Notes/Shopping list.md",
+		"iCloud/Recently Deleted/Trashed.md",
+		"iCloud/Recipes/Desserts/Chocolate cake attachments/cake.jpeg",
+		"iCloud/Recipes/Desserts/Chocolate cake.md",
+	}))
+
+	data, err := os.ReadFile(filepath.Join(dst, "iCloud", "Recipes", "Desserts", "Chocolate cake.md"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(data), `---
+title: "Chocolate cake"
+id: "NOTE-CAKE"
+created: 2021-06-07T08:00:00Z
+modified: 2023-05-06T18:45:30Z
+folder: "Recipes/Desserts"
+pinned: false
+---
+
+# Chocolate cake
+
+## Ingredients
+
+**Dark** chocolate, *butter*, ***sugar***, <ins>eggs</ins>, ~~flour~~ 🥚
+
+### Steps
+
+1. Melt
+    1. Gently
+1. Bake
+- [x] Buy cocoa
+- [ ] Buy cream
+- Dots
+- Dashes
+
+`+"```"+`
+oven.temp = 180
+`+"```"+`
+
+> Let it cool.
+
+[Recipe](<https://example.com/cake>) from Grandma H<sub>2</sub>O
+
+![cake.jpeg](Chocolate%20cake%20attachments/cake.jpeg)
+
+<https://example.com/cocoa>
+
+| Ingredient | Quantity |
+| --- | --- |
+| Flour | **200 g** |
+`))
+
+	data, err = os.ReadFile(filepath.Join(dst, "On My Mac", "Notes", "Secret.md"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), "locked: true\n---\n\n*This note is locked.*\n"))
+}
+
+func TestExportHTML(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "notes")
+	n, err := Export(fixture, container(t), dst, HTML)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, 4))
+	assert.Check(t, is.DeepEqual(listFiles(t, dst), []string{
+		"On My Mac/Notes/Secret.html",
+		"On My Mac/Notes/index.html",
+		"On My Mac/index.html",
+		"iCloud/Notes/Shopping list.html",
+		"iCloud/Notes/index.html",
+		"iCloud/Recently Deleted/Trashed.html",
+		"iCloud/Recently Deleted/index.html",
+		"iCloud/Recipes/Desserts/Chocolate cake.html",
+		"iCloud/Recipes/Desserts/index.html",
+		"iCloud/Recipes/index.html",
+		"iCloud/index.html",
+		"index.html",
+	}))
+
+	data, err := os.ReadFile(filepath.Join(dst, "iCloud", "Recipes", "Desserts", "Chocolate cake.html"))
+	assert.NilError(t, err)
+	page := string(data)
+	assert.Check(t, is.Contains(page, "<title>Chocolate cake</title>"))
+	assert.Check(t, is.Contains(page, `<nav><a href="index.html">Recipes / Desserts</a></nav>`))
+	assert.Check(t, is.Contains(page, "<h1>Chocolate cake</h1>\n<h2>Ingredients</h2>\n"))
+	assert.Check(t, is.Contains(page, "<p><b>Dark</b> chocolate, <i>butter</i>, <b><i>sugar</i></b>, <u>eggs</u>, "+
+		"<s>flour</s> 🥚</p>\n<h3>Steps</h3>\n"))
+	assert.Check(t, is.Contains(page, "<ol>\n<li>Melt<ol>\n<li>Gently</li>\n</ol>\n</li>\n<li>Bake</li>\n</ol>\n"))
+	assert.Check(t, is.Contains(page, `<ul class="checklist">`+"\n"+
+		`<li class="checked"><input type="checkbox" checked disabled>Buy cocoa</li>`+"\n"+
+		`<li><input type="checkbox" disabled>Buy cream</li>`+"\n</ul>\n"))
+	assert.Check(t, is.Contains(page, "<ul>\n<li>Dots</li>\n</ul>\n<ul class=\"dashed\">\n<li>Dashes</li>\n</ul>\n"))
+	assert.Check(t, is.Contains(page, "<pre><code>oven.temp = 180</code></pre>\n"))
+	assert.Check(t, is.Contains(page, "<blockquote>\n<p>Let it cool.</p>\n</blockquote>\n"))
+	assert.Check(t, is.Contains(page, `<a href="https://example.com/cake">Recipe</a> from <span style="font-family: &#39;Noteworthy-Bold&#39;; `+
+		`font-size: 18pt; color: rgba(255, 0, 0, 1)">Grandma</span> H<sub>2</sub>O`))
+	// Attachments are embedded, so that the page stands alone.
+	assert.Check(t, is.Contains(page, `<img src="data:image/jpeg;base64,SlBFRw==" alt="cake.jpeg">`))
+	assert.Check(t, is.Contains(page, `<a href="https://example.com/cocoa">https://example.com/cocoa</a>`))
+	assert.Check(t, is.Contains(page, "<div><table>\n<tr><td>Ingredient</td><td>Quantity</td></tr>\n"+
+		"<tr><td>Flour</td><td><b>200 g</b></td></tr>\n</table></div>\n"))
+
+	data, err = os.ReadFile(filepath.Join(dst, "index.html"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), `<li>📁 <a href="iCloud/index.html">iCloud</a></li>`+"\n"+
+		`<li>📁 <a href="On%20My%20Mac/index.html">On My Mac</a></li>`))
+	assert.Check(t, !strings.Contains(string(data), "<nav>"))
+
+	data, err = os.ReadFile(filepath.Join(dst, "iCloud", "Recipes", "Desserts", "index.html"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), `<nav><a href="../index.html">Back</a></nav>`+"\n<h1>Desserts</h1>"))
+	assert.Check(t, is.Contains(string(data), `<a href="Chocolate%20cake.html">Chocolate cake</a>`))
+
+	data, err = os.ReadFile(filepath.Join(dst, "On My Mac", "Notes", "Secret.html"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), `<p class="attachment">This note is locked.</p>`))
+}
+
+func TestExportHTMLIndexTitle(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "NoteStore.sqlite")
+	data, err := os.ReadFile(fixture)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(db, data, 0644))
+	conn, err := sql.Open("sqlite", db)
+	assert.NilError(t, err)
+	_, err = conn.Exec("UPDATE ZICCLOUDSYNCINGOBJECT SET ZTITLE1 = 'Index' WHERE ZIDENTIFIER = 'NOTE-SHOPPING'")
+	assert.NilError(t, err)
+	assert.NilError(t, conn.Close())
+
+	// The note does not take the name of the index page.
+	dst := filepath.Join(t.TempDir(), "notes")
+	_, err = Export(db, container(t), dst, HTML)
+	assert.NilError(t, err)
+	data, err = os.ReadFile(filepath.Join(dst, "iCloud", "Notes", "Index (2).html"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), "<title>Index</title>"))
+	data, err = os.ReadFile(filepath.Join(dst, "iCloud", "Notes", "index.html"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), `<a href="Index%20%282%29.html">📌 Index</a>`))
+}
+
+func TestExportHTMLLargeAttachment(t *testing.T) {
+	defer func(size int64) { maxEmbeddedSize = size }(maxEmbeddedSize)
+	maxEmbeddedSize = 3
+
+	// Attachments too large to embed are copied next to the page instead.
+	dst := filepath.Join(t.TempDir(), "notes")
+	_, err := Export(fixture, container(t), dst, HTML)
+	assert.NilError(t, err)
+	data, err := os.ReadFile(filepath.Join(dst, "iCloud", "Recipes", "Desserts", "Chocolate cake attachments", "cake.jpeg"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(data), "JPEG"))
+	data, err = os.ReadFile(filepath.Join(dst, "iCloud", "Recipes", "Desserts", "Chocolate cake.html"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), `<img src="Chocolate%20cake%20attachments/cake.jpeg" alt="cake.jpeg">`))
+}
+
+func TestHTMLEmbeddedFile(t *testing.T) {
+	n := &note{
+		document: &notestore.Document{Runs: []notestore.Run{
+			{Text: "\ufffc", Attachment: &notestore.AttachmentRef{Identifier: "PDF", TypeUTI: "com.adobe.pdf"},
+				Paragraph: notestore.Paragraph{Style: notestore.StyleBody}},
+			{Text: "\n", Paragraph: notestore.Paragraph{Style: notestore.StyleBody}},
+		}},
+		attachments: map[string]notestore.Attachment{"PDF": {Identifier: "PDF", TypeUTI: "com.adobe.pdf"}},
+		files:       map[string]string{"PDF": "menu.pdf"},
+		embedded:    map[string]string{"PDF": dataURL("menu.pdf", []byte("%PDF"))},
+	}
+	assert.Check(t, is.Contains(string(renderHTML(n)),
+		`<p><a href="data:application/pdf;base64,JVBERg==" download="menu.pdf">menu.pdf</a></p>`))
+}
+
+func TestHTMLEscape(t *testing.T) {
+	n := &note{document: &notestore.Document{Runs: []notestore.Run{
+		{Text: "<script>alert(1)</script> & co\n", Paragraph: notestore.Paragraph{Style: notestore.StyleBody}},
+		{Text: "red", Font: notestore.Font{Name: `Evil"Font`, Size: 14}, Color: &notestore.Color{Red: 1, Alpha: 1}},
+		{Text: "\n", Paragraph: notestore.Paragraph{Style: notestore.StyleBody, Alignment: notestore.AlignCenter}},
+	}}}
+	page := string(renderHTML(n))
+	assert.Check(t, is.Contains(page, "<p>&lt;script&gt;alert(1)&lt;/script&gt; &amp; co</p>\n"))
+	assert.Check(t, is.Contains(page, `<p style="text-align: center"><span style="font-family: &#39;EvilFont&#39;; `+
+		`font-size: 14pt; color: rgba(255, 0, 0, 1)">red</span></p>`))
+}
+
+func TestHTMLLinks(t *testing.T) {
+	body := notestore.Paragraph{Style: notestore.StyleBody}
+	n := &note{
+		document: &notestore.Document{Runs: []notestore.Run{
+			{Text: "site", Link: "https://example.com/a?b=1&c=2", Paragraph: body},
+			{Text: " ", Paragraph: body},
+			{Text: "mail", Link: "mailto:me@example.com", Paragraph: body},
+			{Text: " ", Paragraph: body},
+			{Text: "click", Link: "JavaScript:alert(1)", Paragraph: body},
+			{Text: " ", Paragraph: body},
+			{Text: "\ufffc", Attachment: &notestore.AttachmentRef{Identifier: "URL", TypeUTI: "public.url"}, Paragraph: body},
+			{Text: "\n", Paragraph: body},
+		}},
+		attachments: map[string]notestore.Attachment{"URL": {Identifier: "URL", TypeUTI: "public.url", URL: "vbscript:msgbox(1)"}},
+	}
+	assert.Check(t, is.Contains(string(renderHTML(n)), `<p><a href="https://example.com/a?b=1&amp;c=2">site</a> `+
+		`<a href="mailto:me@example.com">mail</a> click vbscript:msgbox(1)</p>`))
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("markdown")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(f, Markdown))
+	f, err = ParseFormat("html")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(f, HTML))
+	_, err = ParseFormat("docx")
+	assert.Check(t, is.ErrorContains(err, "unknown export format"))
+}
+
+func TestNames(t *testing.T) {
+	assert.Check(t, is.Equal(safeName(" 2023/04: plans "), "2023-04- plans"))
+	assert.Check(t, is.Equal(safeName("..."), "Untitled"))
+	assert.Check(t, is.Equal(safeName(".hidden"), "hidden"))
+
+	taken := map[string]bool{}
+	assert.Check(t, is.Equal(uniqueName("dir", "Todo", taken), "Todo"))
+	assert.Check(t, is.Equal(uniqueName("dir", "todo", taken), "todo (2)"))
+	assert.Check(t, is.Equal(uniqueName("other", "Todo", taken), "Todo"))
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	n := &note{document: &notestore.Document{Runs: []notestore.Run{
+		{Text: "1. not a list, *really* <b>\n", Paragraph: notestore.Paragraph{Style: notestore.StyleBody}},
+		{Text: "# nor a heading\n", Paragraph: notestore.Paragraph{Style: notestore.StyleBody}},
+	}}}
+	assert.Check(t, is.Contains(string(renderMarkdown(n)), "---\n\n1\\. not a list, \\*really\\* \\<b\\>\n\n\\# nor a heading\n"))
+}
@@ -0,0 +1,437 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/notestore"
+)
+
+// Style sheet embedded in every page, so that pages stand alone.
+const htmlStyle = `body { font-family: -apple-system, "Helvetica Neue", Helvetica, Arial, sans-serif; line-height: 1.5;
+  max-width: 48em; margin: 2em auto; padding: 0 1em; color: #1d1d1f; }
+nav a, nav a:visited { color: #b8860b; text-decoration: none; }
+.meta { color: #86868b; font-size: 0.85em; margin-bottom: 2em; }
+h1, h2, h3 { line-height: 1.25; }
+p { margin: 0.5em 0; }
+pre { background: #f5f5f7; padding: 0.75em; border-radius: 6px; overflow-x: auto; }
+blockquote { border-left: 3px solid #d2d2d7; margin: 0.5em 0; padding-left: 1em; }
+ul.dashed { list-style-type: "– "; }
+ul.checklist { list-style: none; padding-left: 1.2em; }
+ul.checklist > li > input { margin: 0 0.5em 0 -1.2em; }
+li.checked { color: #86868b; text-decoration: line-through; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+td { border: 1px solid #d2d2d7; padding: 0.3em 0.6em; vertical-align: top; }
+img { max-width: 100%; }
+.attachment { color: #86868b; font-style: italic; }
+ul.index { list-style: none; padding: 0; }
+ul.index li { padding: 0.3em 0; border-bottom: 1px solid #f0f0f0; }
+ul.index time { color: #86868b; font-size: 0.85em; float: right; }
+`
+
+// IndexFilename is the name of the index page of each directory of an HTML export.
+const IndexFilename = "index.html"
+
+// Layout of dates shown in pages.
+const htmlTimeLayout = "2006-01-02 15:04"
+
+// Render a note as a standalone HTML page.
+func renderHTML(n *note) []byte {
+	var b bytes.Buffer
+	writeHTMLHead(&b, n.Title)
+	fmt.Fprintf(&b, "<nav><a href=\"%s\">%s</a></nav>\n", IndexFilename, html.EscapeString(strings.Join(n.folder.Path, " / ")))
+	fmt.Fprintf(&b, "<div class=\"meta\">Created %s · Modified %s", htmlTime(n.Created), htmlTime(n.Modified))
+	if n.Pinned {
+		b.WriteString(" · Pinned")
+	}
+	b.WriteString("</div>\n<article>\n")
+	switch {
+	case n.Locked:
+		b.WriteString("<p class=\"attachment\">This note is locked.</p>\n")
+	case n.document != nil:
+		w := &htmlWriter{b: &b, n: n}
+		w.body()
+	}
+	b.WriteString("</article>\n</body>\n</html>\n")
+	return b.Bytes()
+}
+
+func writeHTMLHead(b *bytes.Buffer, title string) {
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(b, "<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n", html.EscapeString(title), htmlStyle)
+}
+
+func htmlTime(t time.Time) string {
+	return fmt.Sprintf("<time datetime=\"%s\">%s</time>", t.Format(time.RFC3339), t.Format(htmlTimeLayout))
+}
+
+// Writes the body of a note, keeping track of the blocks spanning several paragraphs.
+type htmlWriter struct {
+	b *bytes.Buffer
+	n *note
+	// Lists open, from the outermost, and whether their last item is open.
+	lists []htmlList
+	code  bool
+	quote bool
+}
+
+type htmlList struct {
+	style notestore.Style
+	item  bool
+}
+
+func (w *htmlWriter) body() {
+	for _, p := range paragraphs(w.n.document) {
+		if p.BlockQuote != w.quote {
+			w.closeLists(0)
+			w.closeCode()
+			if p.BlockQuote {
+				w.b.WriteString("<blockquote>\n")
+			} else {
+				w.b.WriteString("</blockquote>\n")
+			}
+			w.quote = p.BlockQuote
+		}
+		if p.Style == notestore.StyleMonospaced {
+			w.closeLists(0)
+			if w.code {
+				w.b.WriteString("\n")
+			} else {
+				w.b.WriteString("<pre><code>")
+				w.code = true
+			}
+			w.b.WriteString(html.EscapeString(p.Text()))
+			continue
+		}
+		w.closeCode()
+		if p.Style.IsList() {
+			w.item(p)
+			w.b.WriteString(htmlRuns(p.Runs, w.n))
+			continue
+		}
+		w.closeLists(0)
+		if strings.TrimSpace(p.Text()) == "" {
+			continue
+		}
+		tag := "p"
+		switch p.Style {
+		case notestore.StyleTitle:
+			tag = "h1"
+		case notestore.StyleHeading:
+			tag = "h2"
+		case notestore.StyleSubheading:
+			tag = "h3"
+		default:
+			// Tables are not allowed in paragraphs.
+			for _, r := range p.Runs {
+				if r.Attachment != nil && w.n.tables[r.Attachment.Identifier] != nil {
+					tag = "div"
+				}
+			}
+		}
+		fmt.Fprintf(w.b, "<%s%s>%s</%s>\n", tag, htmlAlignment(p.Alignment), htmlRuns(p.Runs, w.n), tag)
+	}
+	w.closeLists(0)
+	w.closeCode()
+	if w.quote {
+		w.b.WriteString("</blockquote>\n")
+	}
+}
+
+// Open an item of the list of p, opening and closing lists to reach its indentation.
+func (w *htmlWriter) item(p paragraph) {
+	depth := p.Indent + 1
+	w.closeLists(depth)
+	if len(w.lists) == depth && w.lists[depth-1].style != p.Style {
+		w.closeLists(depth - 1)
+	}
+	for len(w.lists) < depth {
+		// A nested list belongs to an item of its parent.
+		if top := len(w.lists) - 1; top >= 0 && !w.lists[top].item {
+			w.b.WriteString("<li>")
+			w.lists[top].item = true
+		}
+		switch p.Style {
+		case notestore.StyleNumberedList:
+			w.b.WriteString("<ol>\n")
+		case notestore.StyleDashedList:
+			w.b.WriteString("<ul class=\"dashed\">\n")
+		case notestore.StyleChecklist:
+			w.b.WriteString("<ul class=\"checklist\">\n")
+		default:
+			w.b.WriteString("<ul>\n")
+		}
+		w.lists = append(w.lists, htmlList{style: p.Style})
+	}
+	top := &w.lists[depth-1]
+	if top.item {
+		w.b.WriteString("</li>\n")
+	}
+	top.item = true
+	switch {
+	case p.Style == notestore.StyleChecklist && p.Checked:
+		w.b.WriteString("<li class=\"checked\"><input type=\"checkbox\" checked disabled>")
+	case p.Style == notestore.StyleChecklist:
+		w.b.WriteString("<li><input type=\"checkbox\" disabled>")
+	default:
+		w.b.WriteString("<li>")
+	}
+}
+
+// Close the lists nested deeper than depth.
+func (w *htmlWriter) closeLists(depth int) {
+	for len(w.lists) > depth {
+		top := w.lists[len(w.lists)-1]
+		if top.item {
+			w.b.WriteString("</li>\n")
+		}
+		if top.style == notestore.StyleNumberedList {
+			w.b.WriteString("</ol>\n")
+		} else {
+			w.b.WriteString("</ul>\n")
+		}
+		w.lists = w.lists[:len(w.lists)-1]
+	}
+}
+
+func (w *htmlWriter) closeCode() {
+	if w.code {
+		w.b.WriteString("</code></pre>\n")
+		w.code = false
+	}
+}
+
+func htmlAlignment(a notestore.Alignment) string {
+	switch a {
+	case notestore.AlignCenter:
+		return ` style="text-align: center"`
+	case notestore.AlignRight:
+		return ` style="text-align: right"`
+	case notestore.AlignJustified:
+		return ` style="text-align: justify"`
+	}
+	return ""
+}
+
+// HTML of runs of text, with their inline attributes.
+func htmlRuns(runs []notestore.Run, n *note) string {
+	var b strings.Builder
+	for _, r := range runs {
+		if r.Attachment != nil {
+			b.WriteString(htmlAttachment(r.Attachment, n))
+			continue
+		}
+		text := strings.ReplaceAll(html.EscapeString(r.Text), "\n", "<br>")
+		if r.Superscript > 0 {
+			text = "<sup>" + text + "</sup>"
+		} else if r.Superscript < 0 {
+			text = "<sub>" + text + "</sub>"
+		}
+		if r.Underline {
+			text = "<u>" + text + "</u>"
+		}
+		if r.Strikethrough {
+			text = "<s>" + text + "</s>"
+		}
+		if r.Italic {
+			text = "<i>" + text + "</i>"
+		}
+		if r.Bold {
+			text = "<b>" + text + "</b>"
+		}
+		if style := htmlFontStyle(r); style != "" {
+			text = "<span style=\"" + html.EscapeString(style) + "\">" + text + "</span>"
+		}
+		if r.Link != "" && safeLink(r.Link) {
+			text = "<a href=\"" + html.EscapeString(r.Link) + "\">" + text + "</a>"
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// Reports whether a link of a note can be followed from a page: web and mail links, and relative ones. Others, such as
+// javascript: URLs, would run in the page.
+func safeLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// CSS of the font and color of a run, if it has any.
+func htmlFontStyle(r notestore.Run) string {
+	var styles []string
+	if r.Font.Name != "" {
+		name := strings.NewReplacer(`"`, "", `'`, "", `\`, "").Replace(r.Font.Name)
+		styles = append(styles, fmt.Sprintf("font-family: '%s'", name))
+	}
+	if r.Font.Size > 0 {
+		styles = append(styles, fmt.Sprintf("font-size: %gpt", r.Font.Size))
+	}
+	if c := r.Color; c != nil {
+		styles = append(styles, fmt.Sprintf("color: rgba(%d, %d, %d, %g)",
+			colorByte(c.Red), colorByte(c.Green), colorByte(c.Blue), c.Alpha))
+	}
+	return strings.Join(styles, "; ")
+}
+
+func colorByte(f float32) int {
+	switch {
+	case f <= 0:
+		return 0
+	case f >= 1:
+		return 255
+	}
+	return int(f*255 + 0.5)
+}
+
+// HTML of an attachment: its table, an image or a link to its file, a link to its URL, or its type.
+func htmlAttachment(ref *notestore.AttachmentRef, n *note) string {
+	if table := n.tables[ref.Identifier]; table != nil {
+		return htmlTable(table, n)
+	}
+	a := n.attachments[ref.Identifier]
+	if file, ok := n.files[ref.Identifier]; ok {
+		name := html.EscapeString(file[strings.LastIndex(file, "/")+1:])
+		link := html.EscapeString((&url.URL{Path: file}).EscapedPath())
+		download := ""
+		if data, ok := n.embedded[ref.Identifier]; ok {
+			link, download = html.EscapeString(data), " download=\""+name+"\""
+		}
+		if imageTypes[a.TypeUTI] {
+			return "<img src=\"" + link + "\" alt=\"" + name + "\">"
+		}
+		return "<a href=\"" + link + "\"" + download + ">" + name + "</a>"
+	}
+	if a.URL != "" {
+		u := html.EscapeString(a.URL)
+		if !safeLink(a.URL) {
+			return u
+		}
+		return "<a href=\"" + u + "\">" + u + "</a>"
+	}
+	return "<span class=\"attachment\">[" + html.EscapeString(ref.TypeUTI) + "]</span>"
+}
+
+func htmlTable(t *notestore.Table, n *note) string {
+	var b strings.Builder
+	b.WriteString("<table>\n")
+	for _, row := range t.Cells {
+		b.WriteString("<tr>")
+		for _, cell := range row {
+			b.WriteString("<td>" + htmlRuns(cell.Runs, n) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
+
+// Index pages of the directories of an export, by path.
+type indexes map[string]*index
+
+// Index page of a directory, listing its subdirectories and notes.
+type index struct {
+	title string
+	root  bool
+	// Subdirectories, by name, with their title.
+	dirs  map[string]string
+	notes []indexEntry
+}
+
+// Note of an index page, with the name of its file.
+type indexEntry struct {
+	name string
+	note notestore.Note
+}
+
+// Register the directory name of parent, titled title, and return its path. An empty name registers parent as the
+// root of the export.
+func (x indexes) add(parent, name, title string) string {
+	dir := filepath.Join(parent, name)
+	if _, ok := x[dir]; !ok {
+		x[dir] = &index{title: title, root: name == "", dirs: map[string]string{}}
+	}
+	if name != "" {
+		x[parent].dirs[name] = title
+	}
+	return dir
+}
+
+// Write the index page of each directory.
+func (x indexes) write() error {
+	for dir, i := range x {
+		if err := os.MkdirAll(dir, dirPerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, IndexFilename), i.render(), filePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *index) render() []byte {
+	var b bytes.Buffer
+	writeHTMLHead(&b, i.title)
+	if !i.root {
+		fmt.Fprintf(&b, "<nav><a href=\"../%s\">Back</a></nav>\n", IndexFilename)
+	}
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(i.title))
+
+	if len(i.dirs) > 0 {
+		names := make([]string, 0, len(i.dirs))
+		for name := range i.dirs {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(a, b int) bool {
+			return strings.ToLower(i.dirs[names[a]]) < strings.ToLower(i.dirs[names[b]])
+		})
+		b.WriteString("<ul class=\"index\">\n")
+		for _, name := range names {
+			link := html.EscapeString((&url.URL{Path: name + "/" + IndexFilename}).EscapedPath())
+			fmt.Fprintf(&b, "<li>📁 <a href=\"%s\">%s</a></li>\n", link, html.EscapeString(i.dirs[name]))
+		}
+		b.WriteString("</ul>\n")
+	}
+
+	if len(i.notes) > 0 {
+		// Pinned notes first, then the most recently modified, as in Notes.
+		notes := append([]indexEntry(nil), i.notes...)
+		sort.SliceStable(notes, func(a, b int) bool {
+			if notes[a].note.Pinned != notes[b].note.Pinned {
+				return notes[a].note.Pinned
+			}
+			return notes[a].note.Modified.After(notes[b].note.Modified)
+		})
+		b.WriteString("<ul class=\"index\">\n")
+		for _, e := range notes {
+			link := html.EscapeString((&url.URL{Path: e.name}).EscapedPath())
+			title := html.EscapeString(e.note.Title)
+			if e.note.Pinned {
+				title = "📌 " + title
+			}
+			if e.note.Locked {
+				title = "🔒 " + title
+			}
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a> %s</li>\n", link, title, htmlTime(e.note.Modified))
+		}
+		b.WriteString("</ul>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
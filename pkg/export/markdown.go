@@ -0,0 +1,206 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/notestore"
+)
+
+// Characters with a meaning in Markdown, escaped in text.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `~`, `\~`, `|`, `\|`,
+	`&`, `\&`,
+)
+
+// Text which would start a heading, a list item or a thematic break at the start of a line.
+var markdownBlockStart = regexp.MustCompile(`^(#|\+|-|=|\d+[.)])`)
+
+// Uniform type identifiers of images, shown inline.
+var imageTypes = map[string]bool{
+	"public.jpeg":              true,
+	"public.png":               true,
+	"public.heic":              true,
+	"public.tiff":              true,
+	"public.image":             true,
+	"com.compuserve.gif":       true,
+	"org.webmproject.webp":     true,
+	"com.apple.drawing":        true,
+	"com.apple.paper.doc.scan": true,
+}
+
+// Render a note as Markdown, with YAML front matter.
+func renderMarkdown(n *note) []byte {
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(n.Title))
+	fmt.Fprintf(&b, "id: %s\n", strconv.Quote(n.Identifier))
+	fmt.Fprintf(&b, "created: %s\n", n.Created.Format(time.RFC3339))
+	fmt.Fprintf(&b, "modified: %s\n", n.Modified.Format(time.RFC3339))
+	fmt.Fprintf(&b, "folder: %s\n", strconv.Quote(strings.Join(n.folder.Path, "/")))
+	fmt.Fprintf(&b, "pinned: %t\n", n.Pinned)
+	if n.Locked {
+		b.WriteString("locked: true\n")
+	}
+	b.WriteString("---\n")
+
+	switch {
+	case n.Locked:
+		b.WriteString("\n*This note is locked.*\n")
+	case n.document != nil:
+		writeMarkdownBody(&b, n)
+	}
+	return b.Bytes()
+}
+
+func writeMarkdownBody(b *bytes.Buffer, n *note) {
+	code, list := false, false
+	for _, p := range paragraphs(n.document) {
+		if p.Style == notestore.StyleMonospaced {
+			if !code {
+				b.WriteString("\n```\n")
+				code, list = true, false
+			}
+			b.WriteString(p.Text() + "\n")
+			continue
+		}
+		if code {
+			b.WriteString("```\n")
+			code = false
+		}
+		text := markdownRuns(p.Runs, n)
+		if strings.TrimSpace(text) == "" {
+			list = false
+			continue
+		}
+
+		// Items of a list are not separated by blank lines, other blocks are.
+		if !p.Style.IsList() || !list {
+			b.WriteString("\n")
+		}
+		list = p.Style.IsList()
+		if p.BlockQuote {
+			b.WriteString("> ")
+		}
+		switch p.Style {
+		case notestore.StyleTitle:
+			b.WriteString("# ")
+		case notestore.StyleHeading:
+			b.WriteString("## ")
+		case notestore.StyleSubheading:
+			b.WriteString("### ")
+		case notestore.StyleDottedList, notestore.StyleDashedList:
+			b.WriteString(strings.Repeat("    ", p.Indent) + "- ")
+		case notestore.StyleNumberedList:
+			b.WriteString(strings.Repeat("    ", p.Indent) + "1. ")
+		case notestore.StyleChecklist:
+			b.WriteString(strings.Repeat("    ", p.Indent))
+			if p.Checked {
+				b.WriteString("- [x] ")
+			} else {
+				b.WriteString("- [ ] ")
+			}
+		default:
+			if loc := markdownBlockStart.FindStringIndex(text); loc != nil {
+				text = text[:loc[1]-1] + `\` + text[loc[1]-1:]
+			}
+		}
+		b.WriteString(text + "\n")
+	}
+	if code {
+		b.WriteString("```\n")
+	}
+}
+
+// Markdown of runs of text, with their inline attributes.
+func markdownRuns(runs []notestore.Run, n *note) string {
+	var b strings.Builder
+	for _, r := range runs {
+		if r.Attachment != nil {
+			b.WriteString(markdownAttachment(r.Attachment, n))
+			continue
+		}
+		// Markers must touch the text they enclose.
+		text := markdownEscaper.Replace(r.Text)
+		core := strings.TrimSpace(text)
+		if core == "" {
+			b.WriteString(text)
+			continue
+		}
+		start := strings.Index(text, core)
+		prefix, suffix := text[:start], text[start+len(core):]
+
+		if r.Superscript > 0 {
+			core = "<sup>" + core + "</sup>"
+		} else if r.Superscript < 0 {
+			core = "<sub>" + core + "</sub>"
+		}
+		if r.Underline {
+			core = "<ins>" + core + "</ins>"
+		}
+		if r.Strikethrough {
+			core = "~~" + core + "~~"
+		}
+		switch {
+		case r.Bold && r.Italic:
+			core = "***" + core + "***"
+		case r.Bold:
+			core = "**" + core + "**"
+		case r.Italic:
+			core = "*" + core + "*"
+		}
+		if r.Link != "" {
+			core = "[" + core + "](<" + r.Link + ">)"
+		}
+		b.WriteString(prefix + core + suffix)
+	}
+	return b.String()
+}
+
+// Markdown of an attachment: its table, an image or a link to its file, a link to its URL, or its type.
+func markdownAttachment(ref *notestore.AttachmentRef, n *note) string {
+	if table := n.tables[ref.Identifier]; table != nil {
+		return markdownTable(table, n)
+	}
+	a := n.attachments[ref.Identifier]
+	if file, ok := n.files[ref.Identifier]; ok {
+		name := markdownEscaper.Replace(file[strings.LastIndex(file, "/")+1:])
+		link := (&url.URL{Path: file}).EscapedPath()
+		if imageTypes[a.TypeUTI] {
+			return "![" + name + "](" + link + ")"
+		}
+		return "[" + name + "](" + link + ")"
+	}
+	if a.URL != "" {
+		return "<" + a.URL + ">"
+	}
+	return "*\\[" + markdownEscaper.Replace(ref.TypeUTI) + "\\]*"
+}
+
+// Markdown table, whose first row is the header as Markdown requires one. Paragraphs of cells are separated by line
+// breaks.
+func markdownTable(t *notestore.Table, n *note) string {
+	var b strings.Builder
+	for i, row := range t.Cells {
+		b.WriteString("|")
+		for _, cell := range row {
+			var lines []string
+			for _, p := range paragraphs(&cell) {
+				lines = append(lines, strings.TrimSpace(markdownRuns(p.Runs, n)))
+			}
+			b.WriteString(" " + strings.Join(lines, "<br>") + " |")
+		}
+		if i == 0 {
+			b.WriteString("\n|" + strings.Repeat(" --- |", len(row)))
+		}
+		if i < len(t.Cells)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package git
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+)
+
+// Branch of the repository, local or fetched from the primary remote.
+type Branch struct {
+	Name string
+	Hash plumbing.Hash
+	// When the last commit of the branch was made.
+	When time.Time
+}
+
+// Check out the configured branch, creating it from the remote branch if any, else from HEAD.
+func (r *Repo) checkoutBranch(gitRepo *git.Repository) error {
+	if r.branch == "" {
+		return nil
+	}
+	name := plumbing.NewBranchReferenceName(r.branch)
+	head, err := gitRepo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Name() == name {
+		return nil
+	}
+	w, err := gitRepo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	_, err = gitRepo.Reference(name, false)
+	if err == nil {
+		return w.Checkout(&git.CheckoutOptions{Branch: name})
+	}
+	if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+	from := head.Hash()
+	if remoteRef, err := gitRepo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, r.branch), true); err == nil {
+		from = remoteRef.Hash()
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: name, Hash: from, Create: true}); err != nil {
+		return errors.Wrapf(err, "failed to create branch %s", r.branch)
+	}
+	return nil
+}
+
+// Branches whose name starts with prefix, local or fetched from the primary remote, sorted by name.
+func (r *Repo) Branches(prefix string) ([]Branch, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := gitRepo.References()
+	if err != nil {
+		return nil, err
+	}
+	remotePrefix := git.DefaultRemoteName + "/"
+	branches := map[string]Branch{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		var name string
+		switch {
+		case ref.Name().IsBranch():
+			name = ref.Name().Short()
+		case ref.Name().IsRemote() && strings.HasPrefix(ref.Name().Short(), remotePrefix):
+			name = strings.TrimPrefix(ref.Name().Short(), remotePrefix)
+		default:
+			return nil
+		}
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		commit, err := gitRepo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		// Keep the most recent of the local and remote branches.
+		if b, ok := branches[name]; ok && !commit.Committer.When.After(b.When) {
+			return nil
+		}
+		branches[name] = Branch{Name: name, Hash: ref.Hash(), When: commit.Committer.When}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Branch, 0, len(branches))
+	for _, b := range branches {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// ResolveBranch returns the last commit of the branch, local or fetched from the primary remote.
+func (r *Repo) ResolveBranch(name string) (plumbing.Hash, error) {
+	branches, err := r.Branches(name)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for _, b := range branches {
+		if b.Name == name {
+			return b.Hash, nil
+		}
+	}
+	return plumbing.ZeroHash, errors.Errorf("branch %s not found", name)
+}
+
+// CommitToBranch commits the staged changes to branch, a new branch started from HEAD, and leaves HEAD and the
+// worktree at the last commit.
+func (r *Repo) CommitToBranch(branch, message string) (plumbing.Hash, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := gitRepo.Reference(name, false); err == nil {
+		return plumbing.ZeroHash, errors.Errorf("branch %s already exists", branch)
+	}
+	hash, err := r.Commit(message)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := gitRepo.Storer.SetReference(plumbing.NewHashReference(name, hash)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := setHead(gitRepo, head.Hash()); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return hash, r.Reset()
+}
@@ -0,0 +1,53 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestDeviceBranches(t *testing.T) {
+	bare := newBareRepo(t)
+	open := func(branch string) *Repo {
+		r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{URL: bare, Branch: branch})
+		assert.NilError(t, err)
+		return r
+	}
+
+	work := open("device/work")
+	home := open("device/home")
+	for _, r := range []*Repo{work, home} {
+		assert.NilError(t, os.MkdirAll(filepath.Join(r.Dir(), "backup"), DirPerm))
+		assert.NilError(t, os.WriteFile(filepath.Join(r.Dir(), "backup", "note.txt"), []byte(r.branch), 0644))
+		commitAll(t, r)
+		pushAll(t, r)
+	}
+
+	// Each device only sees its own files after a pull.
+	work = open("device/work")
+	data, err := os.ReadFile(filepath.Join(work.Dir(), "backup", "note.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(data), "device/work"))
+
+	branches, err := work.Branches("device/")
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(branches, 2))
+	assert.Check(t, is.Equal(branches[0].Name, "device/home"))
+	assert.Check(t, is.Equal(branches[1].Name, "device/work"))
+	assert.Check(t, !branches[0].When.IsZero())
+
+	// Another device's backup is read from Git objects.
+	hash, err := work.ResolveBranch("device/home")
+	assert.NilError(t, err)
+	dst := t.TempDir()
+	assert.NilError(t, work.Extract(hash, "backup", dst))
+	data, err = os.ReadFile(filepath.Join(dst, "note.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(data), "device/home"))
+
+	_, err = work.ResolveBranch("device/unknown")
+	assert.Check(t, err != nil)
+}
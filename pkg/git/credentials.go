@@ -0,0 +1,131 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Credential is a username/password pair used to authenticate against a remote.
+type Credential struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+}
+
+// CredentialProvider looks up the credential to use for a remote URL.
+type CredentialProvider interface {
+	// Credential returns the credential for rawURL, or ErrNoCredential if the provider has none.
+	Credential(rawURL string) (*Credential, error)
+}
+
+// ErrNoCredential is returned by providers which have no credential for the requested URL.
+var ErrNoCredential = errors.New("no credential found")
+
+// Chain tries each provider in order and returns the first credential found. A provider failing, e.g. on a wrong
+// passphrase, stops the chain with its error, so that a misconfigured provider is not mistaken for a missing
+// credential.
+type Chain []CredentialProvider
+
+func (c Chain) Credential(rawURL string) (*Credential, error) {
+	for _, p := range c {
+		cred, err := p.Credential(rawURL)
+		if err == nil {
+			return cred, nil
+		}
+		if !errors.Is(err, ErrNoCredential) {
+			return nil, err
+		}
+	}
+	return nil, ErrNoCredential
+}
+
+// EnvProvider reads a token from the first non-empty environment variable.
+type EnvProvider struct {
+	Vars []string
+}
+
+func (p *EnvProvider) Credential(rawURL string) (*Credential, error) {
+	for _, v := range p.Vars {
+		if token := os.Getenv(v); token != "" {
+			return &Credential{Password: token}, nil
+		}
+	}
+	return nil, ErrNoCredential
+}
+
+// FileProvider reads a token from a file. Surrounding whitespace is ignored.
+type FileProvider struct {
+	Path string
+}
+
+func (p *FileProvider) Credential(rawURL string) (*Credential, error) {
+	if p.Path == "" {
+		return nil, ErrNoCredential
+	}
+	data, err := os.ReadFile(p.Path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoCredential
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read token file")
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, ErrNoCredential
+	}
+	return &Credential{Password: token}, nil
+}
+
+// HelperProvider asks the configured git credential helpers using `git credential fill`.
+type HelperProvider struct{}
+
+func (p *HelperProvider) Credential(rawURL string) (*Credential, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, ErrNoCredential
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrNoCredential
+	}
+
+	var in bytes.Buffer
+	in.WriteString("protocol=" + u.Scheme + "\n")
+	in.WriteString("host=" + u.Host + "\n")
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		in.WriteString("path=" + path + "\n")
+	}
+	in.WriteString("\n")
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = &in
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	out, err := cmd.Output()
+	if err != nil {
+		// The helper fails when it has nothing to offer and prompting is disabled.
+		return nil, ErrNoCredential
+	}
+
+	cred := &Credential{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Password = value
+		}
+	}
+	if cred.Password == "" {
+		return nil, ErrNoCredential
+	}
+	return cred, nil
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestChainOrder(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	assert.NilError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+	t.Setenv("NOTESFOREVER_TEST_TOKEN", "")
+
+	chain := Chain{
+		&EnvProvider{Vars: []string{"NOTESFOREVER_TEST_TOKEN"}},
+		&FileProvider{Path: tokenFile},
+	}
+	cred, err := chain.Credential("https://github.com/user/repo")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(cred.Password, "file-token"))
+
+	t.Setenv("NOTESFOREVER_TEST_TOKEN", "env-token")
+	cred, err = chain.Credential("https://github.com/user/repo")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(cred.Password, "env-token"))
+
+	_, err = Chain{&FileProvider{Path: filepath.Join(dir, "missing")}}.Credential("https://github.com")
+	assert.Check(t, is.ErrorIs(err, ErrNoCredential))
+}
+
+// Provider failing to read its credentials.
+type failingProvider struct{}
+
+func (failingProvider) Credential(rawURL string) (*Credential, error) {
+	return nil, errors.New("keychain is locked")
+}
+
+func TestChainFailure(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NilError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+	chain := Chain{&EnvProvider{}, failingProvider{}, &FileProvider{Path: tokenFile}}
+
+	// A failure is not a missing credential, which would fall back to anonymous access.
+	_, err := chain.Credential("https://github.com/user/repo")
+	assert.Check(t, is.ErrorContains(err, "keychain is locked"))
+	assert.Check(t, !errors.Is(err, ErrNoCredential))
+
+	r, err := newRepo(t.TempDir(), Options{Credentials: chain})
+	assert.NilError(t, err)
+	_, _, err = r.BasicAuth("https://github.com/user/repo")
+	assert.Check(t, is.ErrorContains(err, "failed to get credential for https://github.com/user/repo: keychain is locked"))
+	_, err = r.authFor("https://github.com/user/repo")
+	assert.Check(t, is.ErrorContains(err, "keychain is locked"))
+}
+
+func TestStoreProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	store := &StoreProvider{Path: path, Passphrase: "secret"}
+
+	_, err := store.Credential("https://gitea.example.com/user/repo")
+	assert.Check(t, is.ErrorIs(err, ErrNoCredential))
+
+	assert.NilError(t, store.Put("https://gitea.example.com", Credential{Username: "user", Password: "token"}))
+	cred, err := store.Credential("https://gitea.example.com/user/repo")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(*cred, Credential{Username: "user", Password: "token"}))
+
+	_, err = (&StoreProvider{Path: path, Passphrase: "wrong"}).Credential("https://gitea.example.com")
+	assert.ErrorContains(t, err, "wrong passphrase")
+}
@@ -0,0 +1,131 @@
+package git
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// StoreProvider reads credentials from a passphrase-encrypted file, keyed by remote host.
+type StoreProvider struct {
+	Path       string
+	Passphrase string
+}
+
+type storeFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func (p *StoreProvider) Credential(rawURL string) (*Credential, error) {
+	if p.Path == "" || p.Passphrase == "" {
+		return nil, ErrNoCredential
+	}
+	creds, err := p.load()
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, ErrNoCredential
+	}
+	if err != nil {
+		return nil, err
+	}
+	cred, ok := creds[storeKey(rawURL)]
+	if !ok {
+		return nil, ErrNoCredential
+	}
+	return &cred, nil
+}
+
+// Put saves the credential for the host of rawURL, replacing any previous one.
+func (p *StoreProvider) Put(rawURL string, cred Credential) error {
+	if p.Passphrase == "" {
+		return errors.New("credential store passphrase is not set")
+	}
+	creds, err := p.load()
+	if os.IsNotExist(errors.Cause(err)) {
+		creds = map[string]Credential{}
+	} else if err != nil {
+		return err
+	}
+	creds[storeKey(rawURL)] = cred
+	return p.save(creds)
+}
+
+func (p *StoreProvider) load() (map[string]Credential, error) {
+	raw, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read credential store")
+	}
+	var f storeFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, errors.Wrap(err, "failed to decode credential store")
+	}
+	aead, err := p.cipher(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt credential store; wrong passphrase?")
+	}
+	creds := map[string]Credential{}
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, errors.Wrap(err, "failed to decode credential store")
+	}
+	return creds, nil
+}
+
+func (p *StoreProvider) save(creds map[string]Credential) error {
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	f := storeFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := p.cipher(f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = aead.Seal(nil, f.Nonce, plain, nil)
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Path), DirPerm); err != nil {
+		return errors.Wrap(err, "failed to create credential store directory")
+	}
+	return os.WriteFile(p.Path, raw, 0600)
+}
+
+func (p *StoreProvider) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(p.Passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func storeKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
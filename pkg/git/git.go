@@ -5,53 +5,207 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/user"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/floriankarydes/notesforever/pkg/host"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/google/go-github/v55/github"
-	"github.com/keybase/go-keychain"
 	"github.com/pkg/errors"
 )
 
 type Repo struct {
 	dir   string
 	url   string
-	token string
+	creds CredentialProvider
+	ssh   SSHOptions
+	host  HostOptions
+	retry RetryOptions
+
+	mirrors   []Remote
+	branch    string
+	tags      []string
+	diverged  string
+	author    Identity
+	committer Identity
+	signer    signer
+}
+
+// Options configure how a Repo reaches its remote.
+type Options struct {
+	// URL of the remote repository. If empty, a repository named after dir is found or created on the host.
+	URL string
+	// Credentials used to authenticate against the remote. Defaults to DefaultCredentials.
+	Credentials CredentialProvider
+	// SSH authentication, used when URL is an SSH remote.
+	SSH SSHOptions
+	// Host where the repository is found or created when it cannot be cloned.
+	Host HostOptions
+	// Retry of pushes while the remote is unreachable. Defaults to DefaultRetry.
+	Retry RetryOptions
+	// Mirrors pushed to in addition to the primary remote, e.g. a bare repository on a NAS.
+	Mirrors []Remote
+	// Branch to commit to, e.g. one per device. Defaults to the branch checked out by the clone.
+	Branch string
+	// Tags pushed along with the branch, by name prefix, e.g. "snapshot/".
+	Tags []string
+	// Divergence strategy applied when local and remote histories have diverged. Defaults to DivergeLocal.
+	Divergence string
+	// Author of commits. Defaults to the user of the Git configuration.
+	Author Identity
+	// Committer of commits. Defaults to Author.
+	Committer Identity
+	// Signing of commits. Commits are not signed by default.
+	Signing SigningOptions
+}
+
+// Identity of the author or committer of commits.
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// Signatures of the author and committer of commits built without the worktree, falling back to the Git configuration.
+func (r *Repo) signatures(gitRepo *git.Repository, when time.Time) (author, committer *object.Signature, err error) {
+	author = r.author.signature(when)
+	if author == nil {
+		cfg, err := gitRepo.ConfigScoped(config.GlobalScope)
+		if err != nil {
+			return nil, nil, err
+		}
+		author = &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: when}
+		if author.Name == "" {
+			author.Name = "notesforever"
+		}
+	}
+	committer = r.committer.signature(when)
+	if committer == nil {
+		committer = author
+	}
+	return author, committer, nil
+}
+
+// Signature of the identity at when, or nil to let Git pick it from its configuration.
+func (id Identity) signature(when time.Time) *object.Signature {
+	if id.Name == "" && id.Email == "" {
+		return nil
+	}
+	return &object.Signature{Name: id.Name, Email: id.Email, When: when}
+}
+
+// RetryOptions configure the exponential backoff of pushes.
+type RetryOptions struct {
+	// Attempts before giving up.
+	Attempts int
+	// Backoff before the second attempt, doubled after each failure.
+	Backoff time.Duration
+}
+
+// DefaultRetry tries to push 4 times over about 7 seconds.
+var DefaultRetry = RetryOptions{Attempts: 4, Backoff: time.Second}
+
+// HostOptions select the hosting provider of the repository.
+type HostOptions struct {
+	// Provider among "github" (default), "gitlab", "gitea" and "plain". A plain remote is never created.
+	Provider string
+	// URL of the provider; the API root for GitHub Enterprise. Defaults to the public instance.
+	URL string
+	// Owner of the repository to create when URL is empty. Defaults to the authenticated user.
+	Owner string
 }
 
 const DirPerm = 0755
 
+const githubURL = "https://github.com"
+
+// DefaultCredentials looks up a token in GITHUB_AUTH_TOKEN, then in the macOS keychain.
+var DefaultCredentials = Chain{
+	&EnvProvider{Vars: []string{"GITHUB_AUTH_TOKEN"}},
+	&KeychainProvider{},
+}
+
 // Pull Git repository at dir. If dir is empty, clone repository. If url is empty, create GitHub repository using Base(dir) as name.
-func Open(dir, url string) (*Repo, error) {
+func Open(dir string, opts Options) (*Repo, error) {
 	var err error
 
-	// Get token.
-	token := os.Getenv("GITHUB_AUTH_TOKEN")
-	if token == "" {
-		token, err = getGhTokenFromKeychain()
-		if err != nil {
+	r, err := newRepo(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = r.Pull()
+	switch {
+	case err == nil:
+		return r, nil
+	case IsOffline(err):
+		// Keep working on the local history; pending commits are pushed later.
+		log.Printf("failed to pull repo: %s; working offline", err.Error())
+		return r, nil
+	case errors.Is(err, git.ErrRepositoryNotExists):
+		// Only clone when there is no local history, which must never be moved aside.
+		log.Printf("no repository at %s; try to clone", r.dir)
+		if err = r.create(); err != nil {
 			return nil, err
 		}
+		return r, nil
+	default:
+		return nil, errors.Wrap(err, "failed to pull repository")
+	}
+}
+
+// Load the existing Git repository at dir without contacting the remote.
+func Load(dir string, opts Options) (*Repo, error) {
+	if _, err := git.PlainOpen(dir); err != nil {
+		return nil, errors.Wrapf(err, "failed to open repository %s", dir)
 	}
+	return newRepo(dir, opts)
+}
 
+func newRepo(dir string, opts Options) (*Repo, error) {
 	r := &Repo{
 		dir:   dir,
-		url:   url,
-		token: token,
-	}
+		url:   opts.URL,
+		creds: opts.Credentials,
+		ssh:   opts.SSH,
+		host:  opts.Host,
+		retry: opts.Retry,
 
-	if err = r.Pull(); err == nil {
-		return r, nil
+		mirrors:   opts.Mirrors,
+		branch:    opts.Branch,
+		tags:      opts.Tags,
+		diverged:  opts.Divergence,
+		author:    opts.Author,
+		committer: opts.Committer,
 	}
-	log.Printf("failed to pull repo: %s; try to clone", err.Error())
-
-	if err = r.create(); err != nil {
+	if r.creds == nil {
+		r.creds = DefaultCredentials
+	}
+	if r.retry.Attempts == 0 {
+		r.retry.Attempts = DefaultRetry.Attempts
+	}
+	if r.retry.Backoff == 0 {
+		r.retry.Backoff = DefaultRetry.Backoff
+	}
+	switch r.diverged {
+	case "":
+		r.diverged = DivergeLocal
+	case DivergeLocal, DivergeRemote, DivergeAbort:
+	default:
+		return nil, errors.Errorf("unknown divergence strategy %q", r.diverged)
+	}
+	if r.committer == (Identity{}) {
+		r.committer = r.author
+	}
+	signer, err := newSigner(opts.Signing)
+	if err != nil {
 		return nil, err
 	}
-
+	r.signer = signer
 	return r, nil
 }
 
@@ -59,8 +213,9 @@ func (r *Repo) Dir() string {
 	return r.dir
 }
 
+// URL of the primary remote, see remoteURL.
 func (r *Repo) URL() string {
-	return r.url
+	return r.remoteURL()
 }
 
 // Clean all changes Git repository.
@@ -79,8 +234,8 @@ func (r *Repo) Clean() error {
 	return nil
 }
 
-// Pull all changes Git repository.
-func (r *Repo) Pull() error {
+// Reset the worktree to HEAD, discarding uncommitted changes to tracked files.
+func (r *Repo) Reset() error {
 	gitRepo, err := git.PlainOpen(r.dir)
 	if err != nil {
 		return err
@@ -89,156 +244,241 @@ func (r *Repo) Pull() error {
 	if err != nil {
 		return err
 	}
-	err = w.Pull(&git.PullOptions{Auth: r.auth()})
-	if err == git.NoErrAlreadyUpToDate {
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-	ref, err := gitRepo.Head()
-	if err != nil {
-		return err
-	}
-	commit, err := gitRepo.CommitObject(ref.Hash())
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(commit)
-	return nil
+	return w.Reset(&git.ResetOptions{Mode: git.HardReset})
 }
 
-// Push all changes Git repository.
-func (r *Repo) Push() error {
+// Commit the staged changes to the local history.
+func (r *Repo) Commit(message string) (plumbing.Hash, error) {
 	// Opens an already existing repository.
 	gitRepo, err := git.PlainOpen(r.dir)
 	if err != nil {
-		return err
+		return plumbing.ZeroHash, err
 	}
 	w, err := gitRepo.Worktree()
 	if err != nil {
-		return err
+		return plumbing.ZeroHash, err
 	}
-
-	// Commit all files.
-	_, err = w.Add(".")
+	now := time.Now()
+	commit, err := w.Commit(message, &git.CommitOptions{
+		Author:    r.author.signature(now),
+		Committer: r.committer.signature(now),
+	})
 	if err != nil {
-		return err
+		return plumbing.ZeroHash, err
 	}
-	commit, err := w.Commit(time.Now().String(), &git.CommitOptions{})
-	if err != nil {
-		return err
+	if r.signer != nil {
+		// Replace the commit by its signed version.
+		obj, err := gitRepo.CommitObject(commit)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if commit, err = r.storeCommit(gitRepo, obj); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if err := setHead(gitRepo, commit); err != nil {
+			return plumbing.ZeroHash, err
+		}
 	}
 	obj, err := gitRepo.CommitObject(commit)
 	if err != nil {
-		return err
-	}
-
-	// Push to remote.
-	if err := gitRepo.Push(&git.PushOptions{Auth: r.auth()}); err != nil {
-		return err
+		return plumbing.ZeroHash, err
 	}
 
 	fmt.Println(obj)
-	return nil
+	return commit, nil
 }
 
 // Create & clone Git repository.
 func (r *Repo) create() error {
 	var err error
 
-	var name, org string
+	var name, owner string
 	if r.url != "" {
 		// Try to clone now if URL is defined.
 		if err = r.clone(); err == nil {
 			return nil
 		}
+		if r.host.Provider == host.PlainProvider {
+			return err
+		}
 		log.Printf("failed to clone repo: %s; try to create", err.Error())
-		name = filepath.Base(r.url)
-		org = filepath.Base(filepath.Dir(r.url))
+		owner, name = splitRepoPath(r.url, r.host)
 	} else {
 		name = filepath.Base(r.dir)
-		org = ""
+		owner = r.host.Owner
 	}
 
-	// Connect to GitHub.
-	client := github.NewClient(nil).WithAuthToken(r.token)
-	ctx := context.Background()
-	ghUser, _, err := client.Users.Get(ctx, "")
+	// Connect to hosting provider.
+	h, err := r.openHost()
 	if err != nil {
 		return err
 	}
+	ctx := context.Background()
 
 	// Clone if repository already exists.
-	ghRepo, _, err := client.Repositories.Get(ctx, *ghUser.Login, name)
+	hostRepo, err := h.Get(ctx, owner, name)
+	if err != nil && !errors.Is(err, host.ErrNotFound) {
+		return errors.Wrap(err, "failed to get repository")
+	}
 	if err == nil {
-		r.url = *ghRepo.CloneURL
+		r.url = r.pickURL(hostRepo)
 		return r.clone()
 	}
-	log.Printf("failed to get repo: %s", err)
 
 	// Create repository if it does not exist.
-	private := true
-	autoInit := true
-	ghRepo = &github.Repository{Name: &name, Private: &private, AutoInit: &autoInit}
-	ghRepo, _, err = client.Repositories.Create(ctx, org, ghRepo)
+	hostRepo, err = h.Create(ctx, owner, name, host.Private)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "failed to create repository")
 	}
-	r.url = *ghRepo.CloneURL
+	r.url = r.pickURL(hostRepo)
 
 	// Clone repository.
 	return r.clone()
 }
 
+func (r *Repo) openHost() (host.RepoHost, error) {
+	if r.host.Provider == host.PlainProvider {
+		return host.NewPlain(r.url), nil
+	}
+	webURL := r.host.URL
+	if webURL == "" {
+		webURL = host.PublicURL(r.host.Provider)
+	}
+	cred, err := r.creds.Credential(webURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get token for %s", webURL)
+	}
+	return host.New(r.host.Provider, r.host.URL, cred.Password)
+}
+
+// Keep SSH remotes on SSH when the repository is found or created through the API.
+func (r *Repo) pickURL(hostRepo *host.Repository) string {
+	if ep, err := transport.NewEndpoint(r.url); err == nil && ep.Protocol == "ssh" && hostRepo.SSHURL != "" {
+		return hostRepo.SSHURL
+	}
+	return hostRepo.CloneURL
+}
+
+// Owner and name of the repository at rawURL, on the host h. The owner is the full namespace on GitLab and Gitea,
+// e.g. group/subgroup, as their API expects it.
+func splitRepoPath(rawURL string, h HostOptions) (owner, name string) {
+	p := rawURL
+	if ep, err := transport.NewEndpoint(rawURL); err == nil {
+		p = ep.Path
+	}
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	switch h.Provider {
+	case host.GitLabProvider, host.GiteaProvider:
+		// Instances may be served under a path, which is not part of the namespace.
+		if ep, err := transport.NewEndpoint(h.URL); err == nil && strings.Trim(ep.Path, "/") != "" {
+			p = strings.TrimPrefix(p, strings.Trim(ep.Path, "/")+"/")
+		}
+		return path.Dir(p), path.Base(p)
+	}
+	return path.Base(path.Dir(p)), path.Base(p)
+}
+
 // Clone Git repository.
 func (r *Repo) clone() error {
-	if _, err := os.Stat("temp"); !os.IsNotExist(err) {
+	if _, err := os.Stat(r.dir); !os.IsNotExist(err) {
 		if err := os.Rename(r.dir, r.saveDir()); err != nil {
 			return errors.Wrap(err, "failed to save existing directory")
 		}
 	}
-	_, err := git.PlainClone(r.dir, false, &git.CloneOptions{
-		Auth:     r.auth(),
+	auth, err := r.auth()
+	if err != nil {
+		return err
+	}
+	gitRepo, err := git.PlainClone(r.dir, false, &git.CloneOptions{
+		Auth:     auth,
 		URL:      r.url,
 		Progress: os.Stdout,
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to clone repository")
 	}
-	return nil
+	return r.checkoutBranch(gitRepo)
 }
 
+// Directory an existing directory without repository is moved to before cloning: next to it, as the working
+// directory of a service is the root of the file system.
 func (r *Repo) saveDir() string {
-	return "notesforever_GitBackup_" + time.Now().Format("20060102150405")
+	return filepath.Join(filepath.Dir(r.dir), "notesforever_GitBackup_"+time.Now().Format("20060102150405"))
 }
 
-func (r *Repo) auth() *http.BasicAuth {
-	return &http.BasicAuth{
-		Username: "abc123", // yes, this can be anything except an empty string
-		Password: r.token,
+// Authentication for the remote, selected from the scheme of its URL.
+func (r *Repo) auth() (transport.AuthMethod, error) {
+	return r.authFor(r.remoteURL())
+}
+
+// Authentication for the remote at url, selected from its scheme.
+func (r *Repo) authFor(url string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid remote URL")
+	}
+	switch ep.Protocol {
+	case "ssh":
+		return r.sshAuth(ep)
+	case "http", "https":
+		return r.httpAuth(url)
+	default:
+		// Local remotes need no authentication.
+		return nil, nil
 	}
 }
 
-func getGhTokenFromKeychain() (string, error) {
-	service := "git:https://github.com"
-	user, err := user.Current()
+// HTTP authentication. Anonymous if no credential is found, so that public remotes still work.
+func (r *Repo) httpAuth(url string) (transport.AuthMethod, error) {
+	username, password, err := r.BasicAuth(url)
+	if err != nil || username == "" {
+		return nil, err
+	}
+	return &http.BasicAuth{
+		Username: username,
+		Password: password,
+	}, nil
+}
+
+// BasicAuth returns the HTTP credentials for url, e.g. for its LFS server. An empty username means anonymous access.
+func (r *Repo) BasicAuth(url string) (username, password string, err error) {
+	cred, err := r.creds.Credential(url)
+	if errors.Is(err, ErrNoCredential) {
+		log.Printf("no credential found for %s: %s", url, err.Error())
+		return "", "", nil
+	}
 	if err != nil {
-		return "", err
+		return "", "", errors.Wrapf(err, "failed to get credential for %s", url)
+	}
+	username = cred.Username
+	if username == "" {
+		username = "abc123" // yes, this can be anything except an empty string
+	}
+	return username, cred.Password, nil
+}
+
+// URL of the remote: the configured one, else the one of an existing clone, else GitHub.
+func (r *Repo) remoteURL() string {
+	if r.url != "" {
+		return r.url
+	}
+	if gitRepo, err := git.PlainOpen(r.dir); err == nil {
+		if remote, err := gitRepo.Remote(git.DefaultRemoteName); err == nil && len(remote.Config().URLs) > 0 {
+			return remote.Config().URLs[0]
+		}
 	}
-	query := keychain.NewItem()
-	query.SetSecClass(keychain.SecClassGenericPassword)
-	query.SetService(service)
-	query.SetAccount(user.Username)
-	query.SetMatchLimit(keychain.MatchLimitOne)
-	query.SetReturnData(true)
-	results, err := keychain.QueryItem(query)
+	return githubURL
+}
+
+// Point HEAD, or the branch it refers to, at hash.
+func setHead(gitRepo *git.Repository, hash plumbing.Hash) error {
+	head, err := gitRepo.Storer.Reference(plumbing.HEAD)
 	if err != nil {
-		return "", err
+		return err
 	}
-	if len(results) != 1 {
-		return "", errors.New("several token found")
+	name := plumbing.HEAD
+	if head.Type() == plumbing.SymbolicReference {
+		name = head.Target()
 	}
-	return string(results[0].Data), nil
+	return gitRepo.Storer.SetReference(plumbing.NewHashReference(name, hash))
 }
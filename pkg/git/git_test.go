@@ -0,0 +1,207 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// Create a bare repository with a first commit, as hosting providers do.
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	assert.NilError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = test\n\temail = test@example.com\n"), 0644))
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	_, err := git.PlainInit(bare, true)
+	assert.NilError(t, err)
+
+	seed := t.TempDir()
+	seedRepo, err := git.PlainInit(seed, false)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(seed, "README.md"), []byte("notes\n"), 0644))
+	w, err := seedRepo.Worktree()
+	assert.NilError(t, err)
+	_, err = w.Add("README.md")
+	assert.NilError(t, err)
+	_, err = w.Commit("init", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	assert.NilError(t, err)
+	_, err = seedRepo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{bare}})
+	assert.NilError(t, err)
+	assert.NilError(t, seedRepo.Push(&git.PushOptions{}))
+	return bare
+}
+
+func TestCommitAndPush(t *testing.T) {
+	bare := newBareRepo(t)
+	dir := filepath.Join(t.TempDir(), "clone")
+	r, err := Open(dir, Options{URL: bare})
+	assert.NilError(t, err)
+
+	changes, err := r.Stage()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(changes, 0))
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+		commitAll(t, r)
+	}
+	n, err := r.Unpushed("origin")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, 2))
+
+	pushAll(t, r)
+	n, err = r.Unpushed("origin")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, 0))
+
+	// Deletions are committed too.
+	assert.NilError(t, os.Remove(filepath.Join(dir, "a.txt")))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("longer"), 0644))
+	changes, err = r.Stage()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(changes, []Change{
+		{Path: "a.txt", Action: Deleted, Size: 0, Delta: -5},
+		{Path: "b.txt", Action: Modified, Size: 6, Delta: 1},
+	}))
+	_, err = r.Commit("update")
+	assert.NilError(t, err)
+	clone, err := Open(filepath.Join(t.TempDir(), "other"), Options{URL: bare})
+	assert.NilError(t, err)
+	pushAll(t, r)
+	assert.NilError(t, clone.Pull())
+	_, err = os.Stat(filepath.Join(clone.Dir(), "a.txt"))
+	assert.Check(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(clone.Dir(), "b.txt"))
+	assert.NilError(t, err)
+}
+
+func TestCloneSavesExistingDir(t *testing.T) {
+	bare := newBareRepo(t)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "clone")
+	assert.NilError(t, os.MkdirAll(dir, 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("keep"), 0644))
+
+	// The directory is moved next to the clone, whatever the working directory.
+	wd, err := os.Getwd()
+	assert.NilError(t, err)
+	assert.NilError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	r, err := Open(dir, Options{URL: bare})
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(r.Dir(), "README.md"))
+	assert.NilError(t, err)
+	saved, err := filepath.Glob(filepath.Join(parent, "notesforever_GitBackup_*", "keep.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(saved, 1))
+}
+
+func commitAll(t *testing.T, r *Repo) {
+	t.Helper()
+	_, err := r.Stage()
+	assert.NilError(t, err)
+	_, err = r.Commit("test")
+	assert.NilError(t, err)
+}
+
+func TestParseTrailers(t *testing.T) {
+	msg := "Backup: 1 added\n\nSome body.\n\n" + FormatTrailers([]Trailer{{"Backup-Host", "mac"}, {"Backup-Duration", "1.5s"}})
+	assert.Check(t, is.DeepEqual(ParseTrailers(msg), map[string]string{"Backup-Host": "mac", "Backup-Duration": "1.5s"}))
+	assert.Check(t, is.Nil(ParseTrailers("Backup: 1 added\n\nSome body.")))
+}
+
+func TestSplitRepoPath(t *testing.T) {
+	for _, tc := range []struct {
+		url         string
+		host        HostOptions
+		owner, name string
+	}{
+		{"https://github.com/alice/notes.git", HostOptions{}, "alice", "notes"},
+		{"git@github.com:alice/notes.git", HostOptions{Provider: "github"}, "alice", "notes"},
+		{"https://gitlab.com/group/sub/notes.git", HostOptions{Provider: "gitlab"}, "group/sub", "notes"},
+		{"git@gitlab.com:group/sub/notes.git", HostOptions{Provider: "gitlab"}, "group/sub", "notes"},
+		{"https://git.example.com/scm/group/sub/notes", HostOptions{Provider: "gitlab", URL: "https://git.example.com/scm/"}, "group/sub", "notes"},
+		{"https://git.example.com/gitea/backups/notes.git", HostOptions{Provider: "gitea", URL: "https://git.example.com/gitea"}, "backups", "notes"},
+	} {
+		owner, name := splitRepoPath(tc.url, tc.host)
+		assert.Check(t, is.Equal(owner, tc.owner), tc.url)
+		assert.Check(t, is.Equal(name, tc.name), tc.url)
+	}
+}
+
+func pushAll(t *testing.T, r *Repo) {
+	t.Helper()
+	results, err := r.Push()
+	assert.NilError(t, err)
+	for _, res := range results {
+		assert.NilError(t, res.Err, res.Remote)
+	}
+}
+
+func TestPushMirrors(t *testing.T) {
+	primary := newBareRepo(t)
+	nas := filepath.Join(t.TempDir(), "nas.git")
+	_, err := git.PlainInit(nas, true)
+	assert.NilError(t, err)
+	missing := filepath.Join(t.TempDir(), "missing.git")
+
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{
+		URL:     primary,
+		Retry:   RetryOptions{Attempts: 1},
+		Mirrors: []Remote{{Name: "nas", URL: nas}, {Name: "offline", URL: missing}},
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(r.Remotes(), []string{"origin", "nas", "offline"}))
+	commitFile(t, r, "a.txt")
+
+	// A failing mirror is reported but not fatal.
+	results, err := r.Push()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(results, 3))
+	assert.Check(t, results[0].Primary && results[0].Err == nil)
+	assert.Check(t, is.Equal(results[1].Remote, "nas"))
+	assert.Check(t, results[1].Err == nil)
+	assert.Check(t, is.Equal(results[2].Remote, "offline"))
+	assert.Check(t, results[2].Err != nil)
+
+	for remote, want := range map[string]int{"origin": 0, "nas": 0, "offline": 2} {
+		n, err := r.Unpushed(remote)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(n, want), remote)
+	}
+	for _, bare := range []string{primary, nas} {
+		head := headOf(t, bare)
+		assert.Check(t, is.Equal(head, headOf(t, r.Dir())), bare)
+	}
+
+	// A failing primary is fatal.
+	gitRepo, err := git.PlainOpen(r.Dir())
+	assert.NilError(t, err)
+	cfg, err := gitRepo.Config()
+	assert.NilError(t, err)
+	cfg.Remotes["origin"].URLs = []string{missing}
+	assert.NilError(t, gitRepo.SetConfig(cfg))
+	r.url = missing
+	results, err = r.Push()
+	assert.Check(t, err != nil)
+	assert.Check(t, is.Len(results, 3))
+}
+
+func headOf(t *testing.T, dir string) string {
+	t.Helper()
+	gitRepo, err := git.PlainOpen(dir)
+	assert.NilError(t, err)
+	ref, err := gitRepo.Reference(plumbing.NewBranchReferenceName("master"), true)
+	assert.NilError(t, err)
+	return ref.Hash().String()
+}
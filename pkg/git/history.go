@@ -0,0 +1,146 @@
+package git
+
+import (
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// Revision is a commit of the backup history.
+type Revision struct {
+	Hash    plumbing.Hash
+	Tree    plumbing.Hash
+	When    time.Time
+	Message string
+}
+
+// History of the current branch, following first parents, newest first.
+func (r *Repo) History() ([]Revision, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := firstParents(gitRepo, head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, len(commits))
+	for i, c := range commits {
+		revisions[i] = Revision{Hash: c.Hash, Tree: c.TreeHash, When: c.Author.When, Message: c.Message}
+	}
+	return revisions, nil
+}
+
+// Commits from hash following first parents, newest first.
+func firstParents(gitRepo *git.Repository, hash plumbing.Hash) ([]*object.Commit, error) {
+	var commits []*object.Commit
+	for {
+		c, err := gitRepo.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, c)
+		if len(c.ParentHashes) == 0 {
+			return commits, nil
+		}
+		hash = c.ParentHashes[0]
+	}
+}
+
+// Rewrite the history of the current branch as a linear history of the kept commits and HEAD, which keep their
+// tree, author, committer and message. A tag named safetyTag is created at the former HEAD first, so that nothing is
+// lost. The rewritten history is verified before the branch is moved. It returns the rewritten hash of each kept
+// commit.
+func (r *Repo) Rewrite(keep map[plumbing.Hash]bool, safetyTag string) (map[plumbing.Hash]plumbing.Hash, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gitRepo.CreateTag(safetyTag, head.Hash(), nil); err != nil {
+		return nil, errors.Wrapf(err, "failed to create safety tag %s", safetyTag)
+	}
+
+	commits, err := firstParents(gitRepo, head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	var kept []*object.Commit
+	rewritten := map[plumbing.Hash]plumbing.Hash{}
+	parent := plumbing.ZeroHash
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if !keep[c.Hash] && i != 0 {
+			continue
+		}
+		commit := &object.Commit{
+			Author:    c.Author,
+			Committer: c.Committer,
+			Message:   c.Message,
+			TreeHash:  c.TreeHash,
+		}
+		if !parent.IsZero() {
+			commit.ParentHashes = []plumbing.Hash{parent}
+		}
+		if parent, err = r.storeCommit(gitRepo, commit); err != nil {
+			return nil, err
+		}
+		rewritten[c.Hash] = parent
+		kept = append(kept, c)
+	}
+
+	if err := verifyRewrite(gitRepo, parent, kept); err != nil {
+		return nil, errors.Wrapf(err, "rewritten history is invalid; history is left untouched, see tag %s", safetyTag)
+	}
+	if err := setHead(gitRepo, parent); err != nil {
+		return nil, err
+	}
+	return rewritten, nil
+}
+
+// Check that the history from head has the trees of the kept commits, in order.
+func verifyRewrite(gitRepo *git.Repository, head plumbing.Hash, kept []*object.Commit) error {
+	commits, err := firstParents(gitRepo, head)
+	if err != nil {
+		return err
+	}
+	if len(commits) != len(kept) {
+		return errors.Errorf("expected %d commits, found %d", len(kept), len(commits))
+	}
+	for i, c := range commits {
+		original := kept[len(kept)-1-i]
+		if c.TreeHash != original.TreeHash {
+			return errors.Errorf("commit %s has tree %s instead of %s", c.Hash, c.TreeHash, original.TreeHash)
+		}
+		if len(c.ParentHashes) > 1 {
+			return errors.Errorf("commit %s is a merge", c.Hash)
+		}
+		if _, err := c.Tree(); err != nil {
+			return errors.Wrapf(err, "commit %s", c.Hash)
+		}
+	}
+	return nil
+}
+
+// ResolveRevision returns the commit of a revision: a full or abbreviated hash, a tag or a branch.
+func (r *Repo) ResolveRevision(rev string) (plumbing.Hash, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := gitRepo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrapf(err, "failed to resolve %s", rev)
+	}
+	return *hash, nil
+}
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestRewrite(t *testing.T) {
+	bare := newBareRepo(t)
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{URL: bare})
+	assert.NilError(t, err)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		commitFile(t, r, name)
+	}
+	pushAll(t, r)
+	history, err := r.History()
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(history, 5))
+
+	// Keep b.txt and the seed commit; HEAD is always kept.
+	keep := map[plumbing.Hash]bool{history[2].Hash: true, history[4].Hash: true}
+	rewritten, err := r.Rewrite(keep, "prune/test")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(rewritten, 3))
+
+	pruned, err := r.History()
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(pruned, 3))
+	for i, old := range []Revision{history[0], history[2], history[4]} {
+		assert.Check(t, is.Equal(pruned[i].Tree, old.Tree))
+		assert.Check(t, is.Equal(pruned[i].Hash, rewritten[old.Hash]))
+		assert.Check(t, is.Equal(pruned[i].Message, old.Message))
+	}
+
+	// The safety tag keeps the former history.
+	gitRepo, err := git.PlainOpen(r.Dir())
+	assert.NilError(t, err)
+	tag, err := gitRepo.Tag("prune/test")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(tag.Hash(), history[0].Hash))
+
+	// Rewritten history is only pushed when forced.
+	_, err = r.Push()
+	assert.Check(t, err != nil)
+	_, err = r.ForcePush()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(headOf(t, bare), pruned[0].Hash.String()))
+}
+
+func TestPushTags(t *testing.T) {
+	bare := newBareRepo(t)
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{URL: bare, Tags: []string{"snapshot/"}})
+	assert.NilError(t, err)
+	a := commitFile(t, r, "a.txt")
+	b := commitFile(t, r, "b.txt")
+	assert.NilError(t, r.SetTag("snapshot/a", a.Hash))
+	assert.NilError(t, r.SetTag("snapshot/b", b.Hash))
+	assert.NilError(t, r.SetTag("local/b", b.Hash))
+	pushAll(t, r)
+
+	remoteTags := func() []string {
+		gitRepo, err := git.PlainOpen(bare)
+		assert.NilError(t, err)
+		iter, err := gitRepo.Tags()
+		assert.NilError(t, err)
+		var names []string
+		assert.NilError(t, iter.ForEach(func(ref *plumbing.Reference) error {
+			names = append(names, ref.Name().Short())
+			return nil
+		}))
+		return names
+	}
+	assert.Check(t, is.DeepEqual(remoteTags(), []string{"snapshot/a", "snapshot/b"}))
+
+	tags, err := r.Tags("snapshot/")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(tags, []Tag{{"snapshot/a", a.Hash}, {"snapshot/b", b.Hash}}))
+
+	// Deleted tags are only deleted from the remote by a forced push.
+	assert.NilError(t, r.DeleteTag("snapshot/a"))
+	pushAll(t, r)
+	assert.Check(t, is.Len(remoteTags(), 2))
+	_, err = r.ForcePush()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(remoteTags(), []string{"snapshot/b"}))
+}
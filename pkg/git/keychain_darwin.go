@@ -0,0 +1,42 @@
+//go:build cgo
+
+package git
+
+import (
+	"net/url"
+	"os/user"
+
+	"github.com/keybase/go-keychain"
+	"github.com/pkg/errors"
+)
+
+// KeychainProvider reads the token saved in the macOS keychain by the git credential manager.
+type KeychainProvider struct{}
+
+func (p *KeychainProvider) Credential(rawURL string) (*Credential, error) {
+	service := "git:https://github.com"
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		service = "git:https://" + u.Host
+	}
+	user, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(user.Username)
+	query.SetMatchLimit(keychain.MatchLimitOne)
+	query.SetReturnData(true)
+	results, err := keychain.QueryItem(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNoCredential
+	}
+	if len(results) != 1 {
+		return nil, errors.New("several token found")
+	}
+	return &Credential{Password: string(results[0].Data)}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin || !cgo
+
+package git
+
+// KeychainProvider is only available on macOS; elsewhere it never has a credential.
+type KeychainProvider struct{}
+
+func (p *KeychainProvider) Credential(rawURL string) (*Credential, error) {
+	return nil, ErrNoCredential
+}
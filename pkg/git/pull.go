@@ -0,0 +1,121 @@
+package git
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// Divergence strategies, applied when local and remote histories have diverged.
+const (
+	// DivergeLocal keeps the local worktree and records the remote history with a merge commit.
+	DivergeLocal = "local"
+	// DivergeRemote resets the branch to the remote history, dropping unpushed local commits.
+	DivergeRemote = "remote"
+	// DivergeAbort fails with ErrDiverged.
+	DivergeAbort = "abort"
+)
+
+// ErrDiverged is returned by Pull when histories have diverged and the strategy is DivergeAbort.
+var ErrDiverged = errors.New("local and remote histories have diverged")
+
+// Pull all changes Git repository. Local commits not pushed yet are kept; diverged histories are resolved with the
+// configured divergence strategy.
+func (r *Repo) Pull() error {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return err
+	}
+	if err := r.checkoutBranch(gitRepo); err != nil {
+		return err
+	}
+	auth, err := r.auth()
+	if err != nil {
+		return err
+	}
+	err = gitRepo.Fetch(&git.FetchOptions{Auth: auth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	head, err := gitRepo.Head()
+	if err != nil {
+		return err
+	}
+	remoteRef, err := gitRepo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, head.Name().Short()), true)
+	if err == plumbing.ErrReferenceNotFound {
+		// The branch is not pushed yet.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if remoteRef.Hash() == head.Hash() {
+		return nil
+	}
+
+	local, err := gitRepo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	remote, err := gitRepo.CommitObject(remoteRef.Hash())
+	if err != nil {
+		return err
+	}
+	if ahead, err := remote.IsAncestor(local); err != nil || ahead {
+		// Local commits are pushed later.
+		return err
+	}
+	behind, err := local.IsAncestor(remote)
+	if err != nil {
+		return err
+	}
+	w, err := gitRepo.Worktree()
+	if err != nil {
+		return err
+	}
+	if behind {
+		// Fast-forward.
+		if err := w.Reset(&git.ResetOptions{Mode: git.MergeReset, Commit: remote.Hash}); err != nil {
+			return err
+		}
+		fmt.Println(remote)
+		return nil
+	}
+
+	switch r.diverged {
+	case DivergeRemote:
+		log.Printf("histories have diverged; dropping local commits up to %s", local.Hash)
+		return w.Reset(&git.ResetOptions{Mode: git.HardReset, Commit: remote.Hash})
+	case DivergeLocal:
+		log.Printf("histories have diverged; keeping local backup over remote commit %s", remote.Hash)
+		return r.mergeLocal(gitRepo, local, remote)
+	default:
+		return errors.Wrapf(ErrDiverged, "local %s and remote %s", local.Hash, remote.Hash)
+	}
+}
+
+// Merge the remote history into the local one, keeping the local tree.
+func (r *Repo) mergeLocal(gitRepo *git.Repository, local, remote *object.Commit) error {
+	author, committer, err := r.signatures(gitRepo, time.Now())
+	if err != nil {
+		return err
+	}
+	merge := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      fmt.Sprintf("Merge remote history %s, keeping local backup", remote.Hash),
+		TreeHash:     local.TreeHash,
+		ParentHashes: []plumbing.Hash{local.Hash, remote.Hash},
+	}
+	hash, err := r.storeCommit(gitRepo, merge)
+	if err != nil {
+		return err
+	}
+	return setHead(gitRepo, hash)
+}
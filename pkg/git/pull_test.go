@@ -0,0 +1,86 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// Push a commit from another clone, then commit locally, so that histories diverge.
+func divergedRepo(t *testing.T, strategy string) (r *Repo, local, remote string) {
+	t.Helper()
+	bare := newBareRepo(t)
+	other, err := Open(filepath.Join(t.TempDir(), "other"), Options{URL: bare})
+	assert.NilError(t, err)
+	r, err = Open(filepath.Join(t.TempDir(), "clone"), Options{URL: bare, Divergence: strategy})
+	assert.NilError(t, err)
+
+	remote = commitFile(t, other, "remote.txt").Hash.String()
+	pushAll(t, other)
+	local = commitFile(t, r, "local.txt").Hash.String()
+	return r, local, remote
+}
+
+func TestPullFastForward(t *testing.T) {
+	bare := newBareRepo(t)
+	other, err := Open(filepath.Join(t.TempDir(), "other"), Options{URL: bare})
+	assert.NilError(t, err)
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{URL: bare, Divergence: DivergeAbort})
+	assert.NilError(t, err)
+
+	commitFile(t, other, "remote.txt")
+	pushAll(t, other)
+	assert.NilError(t, r.Pull())
+	assert.Check(t, is.Equal(headOf(t, r.Dir()), headOf(t, other.Dir())))
+	_, err = os.Stat(filepath.Join(r.Dir(), "remote.txt"))
+	assert.NilError(t, err)
+
+	// Unpushed local commits are kept.
+	local := commitFile(t, r, "local.txt")
+	assert.NilError(t, r.Pull())
+	assert.Check(t, is.Equal(headOf(t, r.Dir()), local.Hash.String()))
+}
+
+func TestPullDivergedLocal(t *testing.T) {
+	r, local, remote := divergedRepo(t, DivergeLocal)
+	assert.NilError(t, r.Pull())
+
+	gitRepo, err := git.PlainOpen(r.Dir())
+	assert.NilError(t, err)
+	head, err := gitRepo.Head()
+	assert.NilError(t, err)
+	merge, err := gitRepo.CommitObject(head.Hash())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(merge.ParentHashes, 2))
+	assert.Check(t, is.Equal(merge.ParentHashes[0].String(), local))
+	assert.Check(t, is.Equal(merge.ParentHashes[1].String(), remote))
+	localCommit, err := gitRepo.CommitObject(merge.ParentHashes[0])
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(merge.TreeHash, localCommit.TreeHash))
+
+	// The merge can be pushed.
+	pushAll(t, r)
+}
+
+func TestPullDivergedRemote(t *testing.T) {
+	r, _, remote := divergedRepo(t, DivergeRemote)
+	assert.NilError(t, r.Pull())
+	assert.Check(t, is.Equal(headOf(t, r.Dir()), remote))
+	_, err := os.Stat(filepath.Join(r.Dir(), "local.txt"))
+	assert.Check(t, os.IsNotExist(err))
+}
+
+func TestPullDivergedAbort(t *testing.T) {
+	r, local, _ := divergedRepo(t, DivergeAbort)
+	assert.Check(t, is.ErrorIs(r.Pull(), ErrDiverged))
+	assert.Check(t, is.Equal(headOf(t, r.Dir()), local))
+
+	// Opening the repository fails instead of cloning it again.
+	_, err := Open(r.Dir(), Options{Divergence: DivergeAbort})
+	assert.Check(t, is.ErrorIs(err, ErrDiverged))
+	assert.Check(t, is.Equal(headOf(t, r.Dir()), local))
+}
@@ -0,0 +1,259 @@
+package git
+
+import (
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/pkg/errors"
+)
+
+// Remote which backups are pushed to.
+type Remote struct {
+	Name string
+	URL  string
+}
+
+// PushResult is the outcome of a push to one remote.
+type PushResult struct {
+	Remote string
+	URL    string
+	// Primary is true for the remote the repository was cloned from.
+	Primary bool
+	Err     error
+}
+
+// Push pending commits to the primary remote, then to every mirror. Each push is retried with exponential backoff
+// while its remote is unreachable. The outcome of every remote is reported, but only a failure of the primary is
+// returned as an error.
+func (r *Repo) Push() ([]PushResult, error) {
+	return r.pushAll(false)
+}
+
+// ForcePush is Push overwriting the remote history, e.g. after a Rewrite.
+func (r *Repo) ForcePush() ([]PushResult, error) {
+	return r.pushAll(true)
+}
+
+func (r *Repo) pushAll(force bool) ([]PushResult, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.configureMirrors(gitRepo); err != nil {
+		return nil, err
+	}
+
+	results := []PushResult{{Remote: git.DefaultRemoteName, URL: r.remoteURL(), Primary: true}}
+	for _, m := range r.mirrors {
+		results = append(results, PushResult{Remote: m.Name, URL: m.URL})
+	}
+	for i := range results {
+		results[i].Err = r.pushWithRetry(gitRepo, results[i].Remote, results[i].URL, force)
+		if results[i].Err != nil && !results[i].Primary {
+			log.Printf("failed to push to mirror %s: %s", results[i].Remote, results[i].Err.Error())
+		}
+	}
+	if err := results[0].Err; err != nil {
+		return results, errors.Wrapf(err, "failed to push to %s", results[0].Remote)
+	}
+	return results, nil
+}
+
+// Names of the remotes pushed to, the primary first.
+func (r *Repo) Remotes() []string {
+	names := []string{git.DefaultRemoteName}
+	for _, m := range r.mirrors {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func (r *Repo) pushWithRetry(gitRepo *git.Repository, remote, url string, force bool) error {
+	delay := r.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := r.push(gitRepo, remote, url, force)
+		if err == nil {
+			return nil
+		}
+		if attempt >= r.retry.Attempts || !IsOffline(err) {
+			return err
+		}
+		log.Printf("failed to push to %s (attempt %d/%d): %s; retry in %s", remote, attempt, r.retry.Attempts, err.Error(), delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (r *Repo) push(gitRepo *git.Repository, remote, url string, force bool) error {
+	auth, err := r.authFor(url)
+	if err != nil {
+		return err
+	}
+	opts := &git.PushOptions{RemoteName: remote, Auth: auth, Force: force}
+	// Only push our own branch, other ones may belong to other devices.
+	head, err := gitRepo.Head()
+	if err != nil {
+		return err
+	}
+	specs := []string{head.Name().String() + ":" + head.Name().String()}
+	for _, prefix := range r.tags {
+		ref := plumbing.NewTagReferenceName(prefix + "*").String()
+		specs = append(specs, ref+":"+ref)
+	}
+	for _, spec := range specs {
+		if force {
+			spec = "+" + spec
+		}
+		opts.RefSpecs = append(opts.RefSpecs, config.RefSpec(spec))
+	}
+	if force {
+		// Forced pushes also delete the remote tags which were deleted locally.
+		deleted, err := r.deletedTags(gitRepo, remote, auth)
+		if err != nil {
+			return err
+		}
+		for _, ref := range deleted {
+			opts.RefSpecs = append(opts.RefSpecs, config.RefSpec(":"+ref.String()))
+		}
+	}
+	err = gitRepo.Push(opts)
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+// Tags of the pushed namespaces which exist on the remote but not locally.
+func (r *Repo) deletedTags(gitRepo *git.Repository, remote string, auth transport.AuthMethod) ([]plumbing.ReferenceName, error) {
+	if len(r.tags) == 0 {
+		return nil, nil
+	}
+	rem, err := gitRepo.Remote(remote)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := rem.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, err
+	}
+	var deleted []plumbing.ReferenceName
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		for _, prefix := range r.tags {
+			if !strings.HasPrefix(ref.Name().Short(), prefix) {
+				continue
+			}
+			if _, err := gitRepo.Reference(ref.Name(), false); err == plumbing.ErrReferenceNotFound {
+				deleted = append(deleted, ref.Name())
+			}
+			break
+		}
+	}
+	return deleted, nil
+}
+
+// Add the mirrors to the remotes of the repository, or update their URL.
+func (r *Repo) configureMirrors(gitRepo *git.Repository) error {
+	if len(r.mirrors) == 0 {
+		return nil
+	}
+	cfg, err := gitRepo.Config()
+	if err != nil {
+		return err
+	}
+	for _, m := range r.mirrors {
+		if m.Name == "" || m.Name == git.DefaultRemoteName {
+			return errors.Errorf("invalid mirror name %q", m.Name)
+		}
+		if rc, ok := cfg.Remotes[m.Name]; ok {
+			rc.URLs = []string{m.URL}
+			continue
+		}
+		rc := &config.RemoteConfig{Name: m.Name, URLs: []string{m.URL}}
+		if err := rc.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid mirror %s", m.Name)
+		}
+		cfg.Remotes[m.Name] = rc
+	}
+	return gitRepo.SetConfig(cfg)
+}
+
+// Unpushed counts the commits of the current branch which are not on the given remote yet.
+func (r *Repo) Unpushed(remote string) (int, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return 0, err
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return 0, err
+	}
+
+	// Collect the commits known to the remote.
+	pushed := map[plumbing.Hash]bool{}
+	remoteRef, err := gitRepo.Reference(plumbing.NewRemoteReferenceName(remote, head.Name().Short()), true)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return 0, err
+	}
+	if err == nil {
+		iter, err := gitRepo.Log(&git.LogOptions{From: remoteRef.Hash()})
+		if err != nil {
+			return 0, err
+		}
+		if err := iter.ForEach(func(c *object.Commit) error {
+			pushed[c.Hash] = true
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	iter, err := gitRepo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = iter.ForEach(func(c *object.Commit) error {
+		if !pushed[c.Hash] {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// RemoteHead returns the last known commit of the current branch on the given remote, or the zero hash if it was never
+// pushed to or pulled from.
+func (r *Repo) RemoteHead(remote string) (plumbing.Hash, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	head, err := gitRepo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	ref, err := gitRepo.Reference(plumbing.NewRemoteReferenceName(remote, head.Name().Short()), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+// IsOffline reports whether err is caused by the remote being unreachable.
+func IsOffline(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
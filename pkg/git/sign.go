@@ -0,0 +1,200 @@
+package git
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// Signing formats accepted by SigningOptions.
+const (
+	OpenPGPSigning = "openpgp"
+	SSHSigning     = "ssh"
+)
+
+// SigningOptions configure the signature of commits.
+type SigningOptions struct {
+	// Format of the signature, "openpgp" or "ssh". Commits are not signed if empty.
+	Format string
+	// KeyFile is the private key: an armored OpenPGP key, or an OpenSSH private key.
+	KeyFile string
+	// Passphrase decrypting KeyFile, if any.
+	Passphrase string
+}
+
+// Signer signs the encoded content of commits.
+type signer interface {
+	Sign(message io.Reader) (string, error)
+}
+
+func newSigner(opts SigningOptions) (signer, error) {
+	switch opts.Format {
+	case "":
+		return nil, nil
+	case OpenPGPSigning:
+		return newOpenPGPSigner(opts.KeyFile, opts.Passphrase)
+	case SSHSigning:
+		return newSSHSigner(opts.KeyFile, opts.Passphrase)
+	default:
+		return nil, errors.Errorf("unknown signing format %q", opts.Format)
+	}
+}
+
+type openPGPSigner struct {
+	entity *openpgp.Entity
+}
+
+func newOpenPGPSigner(keyFile, passphrase string) (*openPGPSigner, error) {
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open OpenPGP key")
+	}
+	defer f.Close()
+	keyRing, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read OpenPGP key")
+	}
+	if len(keyRing) == 0 || keyRing[0].PrivateKey == nil {
+		return nil, errors.New("no OpenPGP private key found")
+	}
+	entity := keyRing[0]
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt OpenPGP key")
+		}
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			if err := sub.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, errors.Wrap(err, "failed to decrypt OpenPGP subkey")
+			}
+		}
+	}
+	return &openPGPSigner{entity: entity}, nil
+}
+
+func (s *openPGPSigner) Sign(message io.Reader) (string, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, s.entity, message, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// SSH signatures follow the SSHSIG format of OpenSSH, as produced by `ssh-keygen -Y sign -n git`.
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+const (
+	sshSigMagic     = "SSHSIG"
+	sshSigNamespace = "git"
+	sshSigHash      = "sha512"
+)
+
+func newSSHSigner(keyFile, passphrase string) (*sshSigner, error) {
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read SSH key")
+	}
+	var s ssh.Signer
+	if passphrase != "" {
+		s, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	} else {
+		s, err = ssh.ParsePrivateKey(pemBytes)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse SSH key")
+	}
+	return &sshSigner{signer: s}, nil
+}
+
+func (s *sshSigner) Sign(message io.Reader) (string, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return "", err
+	}
+	signedData := sshSigSignedData(h.Sum(nil))
+
+	var sig *ssh.Signature
+	var err error
+	if as, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// SHA-1 RSA signatures are rejected by OpenSSH.
+		sig, err = as.SignWithAlgorithm(rand.Reader, signedData, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = s.signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var blob bytes.Buffer
+	blob.WriteString(sshSigMagic)
+	binary.Write(&blob, binary.BigEndian, uint32(1))
+	writeSSHString(&blob, s.signer.PublicKey().Marshal())
+	writeSSHString(&blob, []byte(sshSigNamespace))
+	writeSSHString(&blob, nil)
+	writeSSHString(&blob, []byte(sshSigHash))
+	writeSSHString(&blob, ssh.Marshal(sig))
+
+	encoded := base64.StdEncoding.EncodeToString(blob.Bytes())
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return armored.String(), nil
+}
+
+// Data actually signed for a message digest.
+func sshSigSignedData(digest []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(sshSigMagic)
+	writeSSHString(&b, []byte(sshSigNamespace))
+	writeSSHString(&b, nil)
+	writeSSHString(&b, []byte(sshSigHash))
+	writeSSHString(&b, digest)
+	return b.Bytes()
+}
+
+func writeSSHString(w *bytes.Buffer, s []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(s)))
+	w.Write(s)
+}
+
+// Store a commit object, signed if signing is configured, and return its hash.
+func (r *Repo) storeCommit(gitRepo *git.Repository, commit *object.Commit) (plumbing.Hash, error) {
+	if r.signer != nil {
+		unsigned := gitRepo.Storer.NewEncodedObject()
+		if err := commit.EncodeWithoutSignature(unsigned); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		reader, err := unsigned.Reader()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		defer reader.Close()
+		if commit.PGPSignature, err = r.signer.Sign(reader); err != nil {
+			return plumbing.ZeroHash, errors.Wrap(err, "failed to sign commit")
+		}
+	}
+	obj := gitRepo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return gitRepo.Storer.SetEncodedObject(obj)
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestCommitIdentity(t *testing.T) {
+	bare := newBareRepo(t)
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{
+		URL:    bare,
+		Author: Identity{Name: "Backup Bot", Email: "bot@example.com"},
+	})
+	assert.NilError(t, err)
+	commit := commitFile(t, r, "a.txt")
+	assert.Check(t, is.Equal(commit.Author.Name, "Backup Bot"))
+	assert.Check(t, is.Equal(commit.Committer.Email, "bot@example.com"))
+	assert.Check(t, is.Equal(commit.PGPSignature, ""))
+}
+
+func TestCommitOpenPGPSignature(t *testing.T) {
+	entity, err := openpgp.NewEntity("Backup Bot", "", "bot@example.com", nil)
+	assert.NilError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	var private, public bytes.Buffer
+	w, err := armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	assert.NilError(t, err)
+	assert.NilError(t, entity.SerializePrivate(w, nil))
+	assert.NilError(t, w.Close())
+	assert.NilError(t, os.WriteFile(keyFile, private.Bytes(), 0600))
+	w, err = armor.Encode(&public, openpgp.PublicKeyType, nil)
+	assert.NilError(t, err)
+	assert.NilError(t, entity.Serialize(w))
+	assert.NilError(t, w.Close())
+
+	bare := newBareRepo(t)
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{
+		URL:     bare,
+		Signing: SigningOptions{Format: OpenPGPSigning, KeyFile: keyFile},
+	})
+	assert.NilError(t, err)
+	commit := commitFile(t, r, "a.txt")
+	_, err = commit.Verify(public.String())
+	assert.NilError(t, err)
+}
+
+func TestCommitSSHSignature(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NilError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	assert.NilError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	bare := newBareRepo(t)
+	r, err := Open(filepath.Join(t.TempDir(), "clone"), Options{
+		URL:     bare,
+		Signing: SigningOptions{Format: SSHSigning, KeyFile: keyFile},
+	})
+	assert.NilError(t, err)
+	commit := commitFile(t, r, "a.txt")
+	assert.Check(t, strings.HasPrefix(commit.PGPSignature, "-----BEGIN SSH SIGNATURE-----\n"))
+
+	// Decode the SSHSIG blob and verify it against the unsigned commit.
+	armored := strings.TrimSpace(commit.PGPSignature)
+	armored = strings.TrimPrefix(armored, "-----BEGIN SSH SIGNATURE-----")
+	armored = strings.TrimSuffix(armored, "-----END SSH SIGNATURE-----")
+	blob, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(armored, "\n", ""))
+	assert.NilError(t, err)
+	var sig struct {
+		Magic     [6]byte
+		Version   uint32
+		PublicKey []byte
+		Namespace string
+		Reserved  string
+		Hash      string
+		Signature []byte
+	}
+	assert.NilError(t, ssh.Unmarshal(blob, &sig))
+	assert.Check(t, is.Equal(string(sig.Magic[:]), "SSHSIG"))
+	assert.Check(t, is.Equal(sig.Namespace, "git"))
+	signingKey, err := ssh.NewPublicKey(pub)
+	assert.NilError(t, err)
+	assert.Check(t, bytes.Equal(sig.PublicKey, signingKey.Marshal()))
+
+	obj := &plumbing.MemoryObject{}
+	assert.NilError(t, commit.EncodeWithoutSignature(obj))
+	reader, err := obj.Reader()
+	assert.NilError(t, err)
+	content, err := io.ReadAll(reader)
+	assert.NilError(t, err)
+	digest := sha512.Sum512(content)
+	var signature ssh.Signature
+	assert.NilError(t, ssh.Unmarshal(sig.Signature, &signature))
+	assert.NilError(t, signingKey.Verify(sshSigSignedData(digest[:]), &signature))
+}
+
+// Commit a new file and return the commit at HEAD.
+func commitFile(t *testing.T, r *Repo, name string) *object.Commit {
+	t.Helper()
+	assert.NilError(t, os.WriteFile(filepath.Join(r.Dir(), name), []byte(name), 0644))
+	commitAll(t, r)
+	gitRepo, err := git.PlainOpen(r.Dir())
+	assert.NilError(t, err)
+	head, err := gitRepo.Head()
+	assert.NilError(t, err)
+	commit, err := gitRepo.CommitObject(head.Hash())
+	assert.NilError(t, err)
+	return commit
+}
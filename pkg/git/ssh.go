@@ -0,0 +1,50 @@
+package git
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/pkg/errors"
+)
+
+// SSHOptions configure authentication against ssh:// and scp-like (git@host:path) remotes.
+type SSHOptions struct {
+	// User to connect as. Defaults to the user of the remote URL, else "git".
+	User string
+	// KeyFile is a private key file. If empty, keys are requested from ssh-agent.
+	KeyFile string
+	// Passphrase decrypting KeyFile, if any.
+	Passphrase string
+	// KnownHosts files used to verify the server key. Defaults to ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts.
+	KnownHosts []string
+}
+
+func (r *Repo) sshAuth(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	user := r.ssh.User
+	if user == "" {
+		user = ep.User
+	}
+	if user == "" {
+		user = "git"
+	}
+
+	hostKeyCallback, err := ssh.NewKnownHostsCallback(r.ssh.KnownHosts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load known hosts")
+	}
+
+	if r.ssh.KeyFile != "" {
+		auth, err := ssh.NewPublicKeysFromFile(user, r.ssh.KeyFile, r.ssh.Passphrase)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load SSH private key")
+		}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, nil
+	}
+
+	auth, err := ssh.NewSSHAgentAuth(user)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to ssh-agent")
+	}
+	auth.HostKeyCallback = hostKeyCallback
+	return auth, nil
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestAuthFromScheme(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	keyFile := filepath.Join(dir, "id_rsa")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	assert.NilError(t, os.WriteFile(keyFile, pemBytes, 0600))
+	knownHosts := filepath.Join(dir, "known_hosts")
+	assert.NilError(t, os.WriteFile(knownHosts, nil, 0600))
+
+	r := &Repo{
+		dir:   dir,
+		creds: Chain{&EnvProvider{Vars: []string{"NOTESFOREVER_TEST_TOKEN"}}},
+		ssh:   SSHOptions{KeyFile: keyFile, KnownHosts: []string{knownHosts}},
+	}
+	t.Setenv("NOTESFOREVER_TEST_TOKEN", "token")
+
+	for _, url := range []string{"git@github.com:user/notes.git", "ssh://deploy@example.com/notes.git"} {
+		r.url = url
+		auth, err := r.auth()
+		assert.NilError(t, err)
+		keys, ok := auth.(*ssh.PublicKeys)
+		assert.Assert(t, ok, "unexpected auth %T for %s", auth, url)
+		assert.Check(t, keys.HostKeyCallback != nil)
+	}
+	auth, _ := r.auth()
+	assert.Check(t, is.Equal(auth.(*ssh.PublicKeys).User, "deploy"))
+
+	r.url = "https://github.com/user/notes.git"
+	auth, err = r.auth()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(auth, &http.BasicAuth{Username: "abc123", Password: "token"}))
+
+	r.url = filepath.Join(dir, "bare.git")
+	auth, err = r.auth()
+	assert.NilError(t, err)
+	assert.Check(t, auth == nil)
+}
@@ -0,0 +1,131 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// Action applied to a file by a commit.
+type Action string
+
+const (
+	Added    Action = "added"
+	Modified Action = "modified"
+	Deleted  Action = "deleted"
+)
+
+// Change of a file staged for the next commit.
+type Change struct {
+	Path   string
+	Action Action
+	// Size of the file after the change, zero if deleted.
+	Size int64
+	// Delta of the file size in bytes.
+	Delta int64
+}
+
+// Stage all changes of the worktree, including deletions, and return them sorted by path.
+func (r *Repo) Stage() ([]Change, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	w, err := gitRepo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	err = w.AddWithOptions(&git.AddOptions{All: true})
+	if errors.Is(err, syscall.ENOTDIR) {
+		// go-git fails to stage a directory replaced by a file; drop the deleted entries from the index first.
+		if err := unstageDeleted(gitRepo, w); err != nil {
+			return nil, err
+		}
+		err = w.AddWithOptions(&git.AddOptions{All: true})
+	}
+	if err != nil {
+		return nil, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sizes before the change are read from the tree of HEAD, if any.
+	var tree *object.Tree
+	head, err := gitRepo.Head()
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+	if err == nil {
+		commit, err := gitRepo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if tree, err = commit.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	oldSize := func(path string) int64 {
+		if tree == nil {
+			return 0
+		}
+		f, err := tree.File(path)
+		if err != nil {
+			return 0
+		}
+		return f.Size
+	}
+
+	var changes []Change
+	for path, s := range status {
+		c := Change{Path: path}
+		switch s.Staging {
+		case git.Added:
+			c.Action = Added
+		case git.Deleted:
+			c.Action = Deleted
+		case git.Modified, git.Renamed, git.Copied:
+			c.Action = Modified
+		default:
+			continue
+		}
+		if c.Action != Deleted {
+			info, err := os.Lstat(filepath.Join(r.dir, path))
+			if err != nil {
+				return nil, err
+			}
+			c.Size = info.Size()
+		}
+		c.Delta = c.Size - oldSize(path)
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// Remove the files deleted from the worktree from the index.
+func unstageDeleted(gitRepo *git.Repository, w *git.Worktree) error {
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	idx, err := gitRepo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for path, s := range status {
+		if s.Worktree == git.Deleted {
+			if _, err := idx.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return gitRepo.Storer.SetIndex(idx)
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// Tag of the repository, pointing at a commit.
+type Tag struct {
+	Name string
+	Hash plumbing.Hash
+}
+
+// Tags whose name starts with prefix, sorted by name.
+func (r *Repo) Tags(prefix string) ([]Tag, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := gitRepo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		hash := ref.Hash()
+		// Peel annotated tags.
+		if tag, err := gitRepo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return err
+			}
+			hash = commit.Hash
+		}
+		tags = append(tags, Tag{Name: name, Hash: hash})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// SetTag points the lightweight tag name at commit hash, creating it if needed.
+func (r *Repo) SetTag(name string, hash plumbing.Hash) error {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return err
+	}
+	if name == "" || strings.ContainsAny(name, " ~^:?*[\\") || strings.Contains(name, "..") {
+		return errors.Errorf("invalid tag %q", name)
+	}
+	return gitRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName(name), hash))
+}
+
+// DeleteTag deletes the tag name.
+func (r *Repo) DeleteTag(name string) error {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return err
+	}
+	return gitRepo.DeleteTag(name)
+}
+
+// Lookup the commit hash.
+func (r *Repo) Lookup(hash plumbing.Hash) (Revision, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return Revision{}, err
+	}
+	c, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return Revision{}, errors.Wrapf(err, "failed to read commit %s", hash)
+	}
+	return Revision{Hash: c.Hash, Tree: c.TreeHash, When: c.Author.When, Message: c.Message}, nil
+}
+
+// TreeSize is the total size of the files under prefix in the tree of commit hash.
+func (r *Repo) TreeSize(hash plumbing.Hash, prefix string) (int64, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return 0, err
+	}
+	commit, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return 0, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return 0, err
+	}
+	if prefix != "" {
+		if tree, err = tree.Tree(prefix); err == object.ErrDirectoryNotFound {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	var size int64
+	err = tree.Files().ForEach(func(f *object.File) error {
+		size += f.Size
+		return nil
+	})
+	return size, err
+}
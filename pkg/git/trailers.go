@@ -0,0 +1,37 @@
+package git
+
+import (
+	"strings"
+)
+
+// Trailer is a "Key: value" line of the last paragraph of a commit message.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// FormatTrailers renders trailers as the last paragraph of a commit message.
+func FormatTrailers(trailers []Trailer) string {
+	var b strings.Builder
+	for _, t := range trailers {
+		b.WriteString(t.Key + ": " + t.Value + "\n")
+	}
+	return b.String()
+}
+
+// ParseTrailers returns the trailers ending a commit message, by key.
+func ParseTrailers(message string) map[string]string {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	trailers := map[string]string{}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil
+		}
+		trailers[key] = strings.TrimSpace(value)
+	}
+	return trailers
+}
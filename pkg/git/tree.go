@@ -0,0 +1,109 @@
+package git
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// Extract the files under prefix in the tree of commit hash into dst, read from Git objects without touching the
+// worktree. An empty prefix extracts the whole tree.
+func (r *Repo) Extract(hash plumbing.Hash, prefix, dst string) error {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return err
+	}
+	commit, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read commit %s", hash)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	if prefix != "" {
+		if tree, err = tree.Tree(prefix); err != nil {
+			return errors.Wrapf(err, "failed to read %s in commit %s", prefix, hash)
+		}
+	}
+	if err := os.MkdirAll(dst, DirPerm); err != nil {
+		return err
+	}
+	return tree.Files().ForEach(func(f *object.File) error {
+		return extractFile(f, filepath.Join(dst, filepath.FromSlash(f.Name)))
+	})
+}
+
+// ReadFile returns the content of the file at name in the tree of commit hash. The error satisfies os.IsNotExist if
+// there is no such file.
+func (r *Repo) ReadFile(hash plumbing.Hash, name string) ([]byte, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read commit %s", hash)
+	}
+	f, err := commit.File(name)
+	if err == object.ErrFileNotFound {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// Reports whether the slash-separated path name stays within the directory it is relative to.
+func isLocal(name string) bool {
+	name = path.Clean(name)
+	return name != ".." && !strings.HasPrefix(name, "../") && !path.IsAbs(name)
+}
+
+func extractFile(f *object.File, dstPath string) error {
+	if !isLocal(f.Name) {
+		return errors.Errorf("invalid path %s", f.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), DirPerm); err != nil {
+		return err
+	}
+	if f.Mode == filemode.Symlink {
+		target, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dstPath)
+	}
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+	reader, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	out, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
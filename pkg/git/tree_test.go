@@ -0,0 +1,35 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"gotest.tools/v3/assert"
+)
+
+func TestExtractInvalidPath(t *testing.T) {
+	storage := memory.NewStorage()
+	obj := storage.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	assert.NilError(t, err)
+	_, err = w.Write([]byte("content"))
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	hash, err := storage.SetEncodedObject(obj)
+	assert.NilError(t, err)
+	blob, err := object.GetBlob(storage, hash)
+	assert.NilError(t, err)
+
+	dst := t.TempDir()
+	for _, name := range []string{"..", "../note.txt", "a/../../note.txt", "/etc/note.txt"} {
+		f := object.NewFile(name, filemode.Regular, blob)
+		assert.Check(t, extractFile(f, filepath.Join(dst, filepath.FromSlash(name))) != nil, name)
+	}
+	f := object.NewFile("a/../note.txt", filemode.Regular, blob)
+	assert.Check(t, extractFile(f, filepath.Join(dst, "note.txt")))
+}
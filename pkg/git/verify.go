@@ -0,0 +1,83 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/pkg/errors"
+)
+
+// Verify reads the commit hash and every object of its tree from the object store, and checks that their content
+// matches their hash. It returns the problems found, by path; the error is only for a repository that cannot be
+// opened.
+func (r *Repo) Verify(hash plumbing.Hash) ([]string, error) {
+	gitRepo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	if err := verifyObject(gitRepo.Storer, plumbing.CommitObject, hash); err != nil {
+		return append(problems, fmt.Sprintf("commit %s: %s", hash, err)), nil
+	}
+	commit, err := gitRepo.CommitObject(hash)
+	if err != nil {
+		return append(problems, fmt.Sprintf("commit %s: %s", hash, err)), nil
+	}
+	verifyTree(gitRepo.Storer, commit.TreeHash, "", &problems)
+	return problems, nil
+}
+
+func verifyTree(s storer.EncodedObjectStorer, hash plumbing.Hash, dir string, problems *[]string) {
+	name := dir
+	if name == "" {
+		name = "/"
+	}
+	if err := verifyObject(s, plumbing.TreeObject, hash); err != nil {
+		*problems = append(*problems, fmt.Sprintf("%s: %s", name, err))
+		return
+	}
+	tree, err := object.GetTree(s, hash)
+	if err != nil {
+		*problems = append(*problems, fmt.Sprintf("%s: %s", name, err))
+		return
+	}
+	for _, e := range tree.Entries {
+		p := path.Join(dir, e.Name)
+		switch e.Mode {
+		case filemode.Dir:
+			verifyTree(s, e.Hash, p, problems)
+		case filemode.Submodule:
+		default:
+			if err := verifyObject(s, plumbing.BlobObject, e.Hash); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: %s", p, err))
+			}
+		}
+	}
+}
+
+// Read an object and check that its content matches its hash.
+func verifyObject(s storer.EncodedObjectStorer, t plumbing.ObjectType, hash plumbing.Hash) error {
+	obj, err := s.EncodedObject(t, hash)
+	if err != nil {
+		return err
+	}
+	reader, err := obj.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	h := plumbing.NewHasher(t, obj.Size())
+	if _, err := io.Copy(h, reader); err != nil {
+		return err
+	}
+	if sum := h.Sum(); sum != hash {
+		return errors.Errorf("corrupt object %s, its content hashes to %s", hash, sum)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package host
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Gitea host, usually self-hosted. Forgejo exposes the same API.
+type Gitea struct {
+	api *apiClient
+}
+
+type giteaRepo struct {
+	Name          string `json:"name"`
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	Private       bool   `json:"private"`
+	DefaultBranch string `json:"default_branch"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// NewGitea connects to the Gitea instance at baseURL.
+func NewGitea(baseURL, token string) *Gitea {
+	return &Gitea{api: newAPIClient(baseURL+"/api/v1", http.Header{"Authorization": {"token " + token}})}
+}
+
+func (h *Gitea) Get(ctx context.Context, owner, name string) (*Repository, error) {
+	owner, err := h.owner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	var r giteaRepo
+	if err := h.api.do(ctx, http.MethodGet, "/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name), nil, &r); err != nil {
+		return nil, err
+	}
+	return r.repository(), nil
+}
+
+func (h *Gitea) Create(ctx context.Context, owner, name string, visibility Visibility) (*Repository, error) {
+	login, err := h.owner(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	path := "/user/repos"
+	if owner != "" && owner != login {
+		path = "/orgs/" + url.PathEscape(owner) + "/repos"
+	}
+	req := map[string]interface{}{
+		"name":      name,
+		"private":   visibility != Public,
+		"auto_init": true,
+	}
+	var r giteaRepo
+	if err := h.api.do(ctx, http.MethodPost, path, req, &r); err != nil {
+		return nil, err
+	}
+	return r.repository(), nil
+}
+
+func (h *Gitea) Visibility(ctx context.Context, owner, name string) (Visibility, error) {
+	repo, err := h.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.Visibility, nil
+}
+
+func (h *Gitea) DefaultBranch(ctx context.Context, owner, name string) (string, error) {
+	repo, err := h.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+// Login of the authenticated user if owner is empty.
+func (h *Gitea) owner(ctx context.Context, owner string) (string, error) {
+	if owner != "" {
+		return owner, nil
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := h.api.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
+
+func (r *giteaRepo) repository() *Repository {
+	visibility := Public
+	if r.Private {
+		visibility = Private
+	}
+	return &Repository{
+		Owner:         r.Owner.Login,
+		Name:          r.Name,
+		CloneURL:      r.CloneURL,
+		SSHURL:        r.SSHURL,
+		Visibility:    visibility,
+		DefaultBranch: r.DefaultBranch,
+	}
+}
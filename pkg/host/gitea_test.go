@@ -0,0 +1,87 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestGitea(t *testing.T) {
+	repos := map[string]giteaRepo{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal(r.Header.Get("Authorization"), "token secret"))
+		json.NewEncoder(w).Encode(map[string]string{"login": "alice"})
+	})
+	mux.HandleFunc("/api/v1/repos/", func(w http.ResponseWriter, r *http.Request) {
+		repo, ok := repos[r.URL.Path[len("/api/v1/repos/"):]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(repo)
+	})
+	create := func(owner string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Check(t, is.Equal(r.Method, http.MethodPost))
+			var req struct {
+				Name     string `json:"name"`
+				Private  bool   `json:"private"`
+				AutoInit bool   `json:"auto_init"`
+			}
+			assert.NilError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Check(t, req.AutoInit)
+			repo := giteaRepo{
+				Name:          req.Name,
+				CloneURL:      "https://gitea.test/" + owner + "/" + req.Name + ".git",
+				SSHURL:        "git@gitea.test:" + owner + "/" + req.Name + ".git",
+				Private:       req.Private,
+				DefaultBranch: "main",
+			}
+			repo.Owner.Login = owner
+			repos[owner+"/"+req.Name] = repo
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(repo)
+		}
+	}
+	mux.HandleFunc("/api/v1/user/repos", create("alice"))
+	mux.HandleFunc("/api/v1/orgs/team/repos", create("team"))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	h, err := New(GiteaProvider, srv.URL, "secret")
+	assert.NilError(t, err)
+	ctx := context.Background()
+
+	_, err = h.Get(ctx, "", "notes")
+	assert.Check(t, is.ErrorIs(err, ErrNotFound))
+
+	repo, err := h.Create(ctx, "", "notes", Private)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(repo.CloneURL, "https://gitea.test/alice/notes.git"))
+
+	repo, err = h.Get(ctx, "", "notes")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(repo, &Repository{
+		Owner:         "alice",
+		Name:          "notes",
+		CloneURL:      "https://gitea.test/alice/notes.git",
+		SSHURL:        "git@gitea.test:alice/notes.git",
+		Visibility:    Private,
+		DefaultBranch: "main",
+	}))
+
+	_, err = h.Create(ctx, "team", "shared", Public)
+	assert.NilError(t, err)
+	visibility, err := h.Visibility(ctx, "team", "shared")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(visibility, Public))
+	branch, err := h.DefaultBranch(ctx, "team", "shared")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(branch, "main"))
+}
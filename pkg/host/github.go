@@ -0,0 +1,109 @@
+package host
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/pkg/errors"
+)
+
+// GitHub host, on github.com or GitHub Enterprise.
+type GitHub struct {
+	client *github.Client
+}
+
+// NewGitHub connects to the GitHub API at apiURL, or to api.github.com if apiURL is empty.
+func NewGitHub(apiURL, token string) (*GitHub, error) {
+	client := github.NewClient(nil).WithAuthToken(token)
+	if apiURL != "" {
+		u, err := url.Parse(strings.TrimSuffix(apiURL, "/") + "/")
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid GitHub API URL")
+		}
+		client.BaseURL = u
+	}
+	return &GitHub{client: client}, nil
+}
+
+func (h *GitHub) Get(ctx context.Context, owner, name string) (*Repository, error) {
+	owner, err := h.owner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	repo, resp, err := h.client.Repositories.Get(ctx, owner, name)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return githubRepository(repo), nil
+}
+
+func (h *GitHub) Create(ctx context.Context, owner, name string, visibility Visibility) (*Repository, error) {
+	login, err := h.owner(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	org := owner
+	if org == login {
+		org = ""
+	}
+	private := visibility != Public
+	autoInit := true
+	repo, _, err := h.client.Repositories.Create(ctx, org, &github.Repository{
+		Name:     &name,
+		Private:  &private,
+		AutoInit: &autoInit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return githubRepository(repo), nil
+}
+
+func (h *GitHub) Visibility(ctx context.Context, owner, name string) (Visibility, error) {
+	repo, err := h.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.Visibility, nil
+}
+
+func (h *GitHub) DefaultBranch(ctx context.Context, owner, name string) (string, error) {
+	repo, err := h.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+// Login of the authenticated user if owner is empty.
+func (h *GitHub) owner(ctx context.Context, owner string) (string, error) {
+	if owner != "" {
+		return owner, nil
+	}
+	user, _, err := h.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get GitHub user")
+	}
+	return user.GetLogin(), nil
+}
+
+func githubRepository(repo *github.Repository) *Repository {
+	visibility := Public
+	if repo.GetPrivate() {
+		visibility = Private
+	}
+	return &Repository{
+		Owner:         repo.GetOwner().GetLogin(),
+		Name:          repo.GetName(),
+		CloneURL:      repo.GetCloneURL(),
+		SSHURL:        repo.GetSSHURL(),
+		Visibility:    visibility,
+		DefaultBranch: repo.GetDefaultBranch(),
+	}
+}
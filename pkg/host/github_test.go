@@ -0,0 +1,97 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestGitHub(t *testing.T) {
+	repos := map[string]map[string]interface{}{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal(r.Header.Get("Authorization"), "Bearer secret"))
+		json.NewEncoder(w).Encode(map[string]string{"login": "alice"})
+	})
+	mux.HandleFunc("/repos/", func(w http.ResponseWriter, r *http.Request) {
+		repo, ok := repos[strings.TrimPrefix(r.URL.Path, "/repos/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Not Found"})
+			return
+		}
+		json.NewEncoder(w).Encode(repo)
+	})
+	create := func(owner string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Check(t, is.Equal(r.Method, http.MethodPost))
+			var req struct {
+				Name     string `json:"name"`
+				Private  bool   `json:"private"`
+				AutoInit bool   `json:"auto_init"`
+			}
+			assert.NilError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Check(t, req.AutoInit)
+			repo := map[string]interface{}{
+				"name":           req.Name,
+				"owner":          map[string]string{"login": owner},
+				"clone_url":      "https://github.test/" + owner + "/" + req.Name + ".git",
+				"ssh_url":        "git@github.test:" + owner + "/" + req.Name + ".git",
+				"private":        req.Private,
+				"default_branch": "main",
+			}
+			repos[owner+"/"+req.Name] = repo
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(repo)
+		}
+	}
+	mux.HandleFunc("/user/repos", create("alice"))
+	mux.HandleFunc("/orgs/team/repos", create("team"))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	h, err := New(GitHubProvider, srv.URL, "secret")
+	assert.NilError(t, err)
+	ctx := context.Background()
+
+	_, err = h.Get(ctx, "", "notes")
+	assert.Check(t, is.ErrorIs(err, ErrNotFound))
+
+	_, err = h.Create(ctx, "alice", "notes", Private)
+	assert.NilError(t, err)
+	repo, err := h.Get(ctx, "", "notes")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(repo, &Repository{
+		Owner:         "alice",
+		Name:          "notes",
+		CloneURL:      "https://github.test/alice/notes.git",
+		SSHURL:        "git@github.test:alice/notes.git",
+		Visibility:    Private,
+		DefaultBranch: "main",
+	}))
+
+	_, err = h.Create(ctx, "team", "shared", Public)
+	assert.NilError(t, err)
+	branch, err := h.DefaultBranch(ctx, "team", "shared")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(branch, "main"))
+}
+
+func TestPlain(t *testing.T) {
+	h, err := New(PlainProvider, "git@nas.local:backups/notes.git", "")
+	assert.NilError(t, err)
+	repo, err := h.Get(context.Background(), "", "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(repo.Owner, "backups"))
+	assert.Check(t, is.Equal(repo.Name, "notes"))
+	assert.Check(t, is.Equal(repo.CloneURL, "git@nas.local:backups/notes.git"))
+
+	_, err = h.Create(context.Background(), "backups", "notes", Private)
+	assert.Check(t, is.ErrorIs(err, ErrUnsupported))
+}
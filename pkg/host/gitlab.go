@@ -0,0 +1,120 @@
+package host
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const gitlabURL = "https://gitlab.com"
+
+// GitLab host, on gitlab.com or a self-managed instance.
+type GitLab struct {
+	api *apiClient
+}
+
+type gitlabProject struct {
+	Path          string `json:"path"`
+	HTTPURL       string `json:"http_url_to_repo"`
+	SSHURL        string `json:"ssh_url_to_repo"`
+	Visibility    string `json:"visibility"`
+	DefaultBranch string `json:"default_branch"`
+	Namespace     struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+// NewGitLab connects to the GitLab instance at baseURL, or to gitlab.com if baseURL is empty.
+func NewGitLab(baseURL, token string) *GitLab {
+	if baseURL == "" {
+		baseURL = gitlabURL
+	}
+	return &GitLab{api: newAPIClient(baseURL+"/api/v4", http.Header{"Private-Token": {token}})}
+}
+
+func (h *GitLab) Get(ctx context.Context, owner, name string) (*Repository, error) {
+	owner, err := h.owner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	var p gitlabProject
+	if err := h.api.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(owner+"/"+name), nil, &p); err != nil {
+		return nil, err
+	}
+	return p.repository(), nil
+}
+
+func (h *GitLab) Create(ctx context.Context, owner, name string, visibility Visibility) (*Repository, error) {
+	req := map[string]interface{}{
+		"name":                   name,
+		"path":                   name,
+		"visibility":             string(visibility),
+		"initialize_with_readme": true,
+	}
+	if owner != "" {
+		login, err := h.owner(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		if owner != login {
+			var ns struct {
+				ID int64 `json:"id"`
+			}
+			if err := h.api.do(ctx, http.MethodGet, "/namespaces/"+url.PathEscape(owner), nil, &ns); err != nil {
+				return nil, err
+			}
+			req["namespace_id"] = ns.ID
+		}
+	}
+	var p gitlabProject
+	if err := h.api.do(ctx, http.MethodPost, "/projects", req, &p); err != nil {
+		return nil, err
+	}
+	return p.repository(), nil
+}
+
+func (h *GitLab) Visibility(ctx context.Context, owner, name string) (Visibility, error) {
+	repo, err := h.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.Visibility, nil
+}
+
+func (h *GitLab) DefaultBranch(ctx context.Context, owner, name string) (string, error) {
+	repo, err := h.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+// Username of the authenticated user if owner is empty.
+func (h *GitLab) owner(ctx context.Context, owner string) (string, error) {
+	if owner != "" {
+		return owner, nil
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := h.api.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+func (p *gitlabProject) repository() *Repository {
+	// GitLab also has an "internal" visibility, which is not public.
+	visibility := Private
+	if p.Visibility == string(Public) {
+		visibility = Public
+	}
+	return &Repository{
+		Owner:         p.Namespace.FullPath,
+		Name:          p.Path,
+		CloneURL:      p.HTTPURL,
+		SSHURL:        p.SSHURL,
+		Visibility:    visibility,
+		DefaultBranch: p.DefaultBranch,
+	}
+}
@@ -0,0 +1,88 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestGitLab(t *testing.T) {
+	projects := map[string]gitlabProject{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal(r.Header.Get("Private-Token"), "secret"))
+		json.NewEncoder(w).Encode(map[string]string{"username": "alice"})
+	})
+	mux.HandleFunc("/api/v4/namespaces/team", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int64{"id": 42})
+	})
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := projects[strings.TrimPrefix(r.URL.Path, "/api/v4/projects/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(p)
+	})
+	mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal(r.Method, http.MethodPost))
+		var req struct {
+			Path        string `json:"path"`
+			Visibility  string `json:"visibility"`
+			Init        bool   `json:"initialize_with_readme"`
+			NamespaceID int64  `json:"namespace_id"`
+		}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Check(t, req.Init)
+		owner := "alice"
+		if req.NamespaceID == 42 {
+			owner = "team"
+		}
+		p := gitlabProject{
+			Path:          req.Path,
+			HTTPURL:       "https://gitlab.test/" + owner + "/" + req.Path + ".git",
+			SSHURL:        "git@gitlab.test:" + owner + "/" + req.Path + ".git",
+			Visibility:    req.Visibility,
+			DefaultBranch: "main",
+		}
+		p.Namespace.FullPath = owner
+		projects[owner+"/"+req.Path] = p
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	h, err := New(GitLabProvider, srv.URL, "secret")
+	assert.NilError(t, err)
+	ctx := context.Background()
+
+	_, err = h.Get(ctx, "", "notes")
+	assert.Check(t, is.ErrorIs(err, ErrNotFound))
+
+	_, err = h.Create(ctx, "", "notes", Private)
+	assert.NilError(t, err)
+	repo, err := h.Get(ctx, "", "notes")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(repo, &Repository{
+		Owner:         "alice",
+		Name:          "notes",
+		CloneURL:      "https://gitlab.test/alice/notes.git",
+		SSHURL:        "git@gitlab.test:alice/notes.git",
+		Visibility:    Private,
+		DefaultBranch: "main",
+	}))
+
+	repo, err = h.Create(ctx, "team", "shared", Public)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(repo.Owner, "team"))
+	visibility, err := h.Visibility(ctx, "team", "shared")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(visibility, Public))
+}
@@ -0,0 +1,146 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Repository hosted by a provider.
+type Repository struct {
+	Owner         string
+	Name          string
+	CloneURL      string
+	SSHURL        string
+	Visibility    Visibility
+	DefaultBranch string
+}
+
+// Visibility of a hosted repository.
+type Visibility string
+
+const (
+	Private Visibility = "private"
+	Public  Visibility = "public"
+)
+
+// RepoHost finds and creates repositories on a hosting provider.
+//
+// An empty owner stands for the authenticated user.
+type RepoHost interface {
+	// Get the repository owner/name, or ErrNotFound if it does not exist.
+	Get(ctx context.Context, owner, name string) (*Repository, error)
+	// Create the repository owner/name, initialized with a first commit so that it can be cloned.
+	Create(ctx context.Context, owner, name string, visibility Visibility) (*Repository, error)
+	// Visibility of the repository owner/name.
+	Visibility(ctx context.Context, owner, name string) (Visibility, error)
+	// DefaultBranch of the repository owner/name.
+	DefaultBranch(ctx context.Context, owner, name string) (string, error)
+}
+
+var (
+	// ErrNotFound is returned when a repository does not exist.
+	ErrNotFound = errors.New("repository not found")
+	// ErrUnsupported is returned by hosts which cannot perform an operation.
+	ErrUnsupported = errors.New("operation not supported by host")
+)
+
+// Providers accepted by New.
+const (
+	GitHubProvider = "github"
+	GitLabProvider = "gitlab"
+	GiteaProvider  = "gitea"
+	PlainProvider  = "plain"
+)
+
+// New returns the host of the given provider. baseURL is the root of the provider (the API root for GitHub); it may
+// be empty for GitHub and GitLab to use the public instances. For the plain provider, baseURL is the remote URL.
+func New(provider, baseURL, token string) (RepoHost, error) {
+	switch provider {
+	case GitHubProvider, "":
+		return NewGitHub(baseURL, token)
+	case GitLabProvider:
+		return NewGitLab(baseURL, token), nil
+	case GiteaProvider:
+		if baseURL == "" {
+			return nil, errors.New("gitea host requires a base URL")
+		}
+		return NewGitea(baseURL, token), nil
+	case PlainProvider:
+		return NewPlain(baseURL), nil
+	default:
+		return nil, errors.Errorf("unknown host provider %q", provider)
+	}
+}
+
+// PublicURL of the public instance of the provider, if any.
+func PublicURL(provider string) string {
+	switch provider {
+	case GitHubProvider, "":
+		return "https://github.com"
+	case GitLabProvider:
+		return gitlabURL
+	default:
+		return ""
+	}
+}
+
+// Minimal JSON REST client shared by the providers without a Go SDK.
+type apiClient struct {
+	baseURL string
+	header  http.Header
+	client  *http.Client
+}
+
+func newAPIClient(baseURL string, header http.Header) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		header:  header,
+		client:  http.DefaultClient,
+	}
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,46 @@
+package host
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Plain host is a bare remote URL. It never calls an API, so repositories must already exist.
+type Plain struct {
+	url string
+}
+
+// NewPlain returns the host of the remote at rawURL.
+func NewPlain(rawURL string) *Plain {
+	return &Plain{url: rawURL}
+}
+
+func (h *Plain) Get(ctx context.Context, owner, name string) (*Repository, error) {
+	if h.url == "" {
+		return nil, ErrNotFound
+	}
+	repo := &Repository{CloneURL: h.url, SSHURL: h.url}
+	if ep, err := transport.NewEndpoint(h.url); err == nil {
+		p := strings.TrimSuffix(strings.Trim(ep.Path, "/"), ".git")
+		repo.Name = path.Base(p)
+		if dir := path.Dir(p); dir != "." {
+			repo.Owner = path.Base(dir)
+		}
+	}
+	return repo, nil
+}
+
+func (h *Plain) Create(ctx context.Context, owner, name string, visibility Visibility) (*Repository, error) {
+	return nil, ErrUnsupported
+}
+
+func (h *Plain) Visibility(ctx context.Context, owner, name string) (Visibility, error) {
+	return "", ErrUnsupported
+}
+
+func (h *Plain) DefaultBranch(ctx context.Context, owner, name string) (string, error) {
+	return "", ErrUnsupported
+}
@@ -0,0 +1,285 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const mediaType = "application/vnd.git-lfs+json"
+
+// Batch operations.
+const (
+	upload   = "upload"
+	download = "download"
+)
+
+// Objects per batch request.
+const batchSize = 100
+
+// Credentials returns the username and password for the LFS server. An empty username means anonymous access.
+type Credentials func() (username, password string, err error)
+
+// Client of the LFS batch API, with the basic transfer adapter.
+type Client struct {
+	url   string
+	creds Credentials
+	http  *http.Client
+}
+
+// NewClient returns a client of the LFS server at url, e.g. https://github.com/owner/repo.git/info/lfs.
+func NewClient(url string, creds Credentials) *Client {
+	return &Client{
+		url:   strings.TrimSuffix(url, "/"),
+		creds: creds,
+		http:  http.DefaultClient,
+	}
+}
+
+// Endpoint returns the LFS server of a Git remote, following the git-lfs conventions. SSH remotes are mapped to
+// their HTTPS counterpart.
+func Endpoint(remoteURL string) (string, error) {
+	u := remoteURL
+	if !strings.Contains(u, "://") {
+		// scp-like syntax: git@host:owner/repo.git
+		host, path, ok := strings.Cut(u, ":")
+		if !ok {
+			return "", errors.Errorf("no LFS endpoint for %s", remoteURL)
+		}
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+		u = "https://" + host + "/" + strings.TrimPrefix(path, "/")
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", errors.Wrapf(err, "no LFS endpoint for %s", remoteURL)
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+	case "ssh":
+		parsed.Scheme = "https"
+		parsed.Host = parsed.Hostname()
+	default:
+		return "", errors.Errorf("no LFS endpoint for %s", remoteURL)
+	}
+	parsed.User = nil
+	if !strings.HasSuffix(parsed.Path, ".git") {
+		parsed.Path += ".git"
+	}
+	parsed.Path += "/info/lfs"
+	return parsed.String(), nil
+}
+
+type batchRequest struct {
+	Operation string         `json:"operation"`
+	Transfers []string       `json:"transfers"`
+	Objects   []batchPointer `json:"objects"`
+	HashAlgo  string         `json:"hash_algo"`
+}
+
+type batchPointer struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type batchResponse struct {
+	Transfer string        `json:"transfer"`
+	Objects  []batchObject `json:"objects"`
+	Message  string        `json:"message"`
+}
+
+type batchObject struct {
+	batchPointer
+	Actions map[string]action `json:"actions"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type action struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+// Upload the objects of the store which are missing on the server.
+func (c *Client) Upload(ctx context.Context, s *Store, pointers []Pointer) error {
+	return c.batches(ctx, upload, pointers, func(obj batchObject) error {
+		a, ok := obj.Actions[upload]
+		if !ok {
+			// Already on the server.
+			return nil
+		}
+		f, err := s.Open(obj.Oid)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		req, err := c.newRequest(ctx, http.MethodPut, a, f)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = obj.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if err := c.do(req, nil); err != nil {
+			return errors.Wrapf(err, "failed to upload LFS object %s", obj.Oid)
+		}
+		if verify, ok := obj.Actions["verify"]; ok {
+			body, err := json.Marshal(obj.batchPointer)
+			if err != nil {
+				return err
+			}
+			req, err := c.newRequest(ctx, http.MethodPost, verify, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", mediaType)
+			if err := c.do(req, nil); err != nil {
+				return errors.Wrapf(err, "failed to verify LFS object %s", obj.Oid)
+			}
+		}
+		return nil
+	})
+}
+
+// Download the objects into the store.
+func (c *Client) Download(ctx context.Context, s *Store, pointers []Pointer) error {
+	return c.batches(ctx, download, pointers, func(obj batchObject) error {
+		a, ok := obj.Actions[download]
+		if !ok {
+			return errors.Errorf("LFS object %s is not available for download", obj.Oid)
+		}
+		req, err := c.newRequest(ctx, http.MethodGet, a, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := checkResponse(resp); err != nil {
+			return errors.Wrapf(err, "failed to download LFS object %s", obj.Oid)
+		}
+		expected := Pointer{Oid: obj.Oid, Size: obj.Size}
+		_, err = s.Put(resp.Body, &expected)
+		return err
+	})
+}
+
+// Run the batch API on the pointers, and transfer each object with fn.
+func (c *Client) batches(ctx context.Context, operation string, pointers []Pointer, fn func(batchObject) error) error {
+	for len(pointers) > 0 {
+		n := len(pointers)
+		if n > batchSize {
+			n = batchSize
+		}
+		batch := batchRequest{Operation: operation, Transfers: []string{"basic"}, HashAlgo: "sha256"}
+		requested := map[batchPointer]bool{}
+		for _, p := range pointers[:n] {
+			obj := batchPointer{Oid: p.Oid, Size: p.Size}
+			batch.Objects = append(batch.Objects, obj)
+			requested[obj] = true
+		}
+		pointers = pointers[n:]
+
+		body, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/objects/batch", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", mediaType)
+		req.Header.Set("Content-Type", mediaType)
+		if err := c.authorize(req); err != nil {
+			return err
+		}
+		var resp batchResponse
+		if err := c.do(req, &resp); err != nil {
+			return errors.Wrap(err, "LFS batch request failed")
+		}
+		if resp.Transfer != "" && resp.Transfer != "basic" {
+			return errors.Errorf("unsupported LFS transfer %q", resp.Transfer)
+		}
+		for _, obj := range resp.Objects {
+			if !requested[obj.batchPointer] {
+				// Never read or write an object the server made up.
+				continue
+			}
+			if obj.Error != nil {
+				return errors.Errorf("LFS object %s: %d %s", obj.Oid, obj.Error.Code, obj.Error.Message)
+			}
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// New request for an action. Credentials are only sent along to the LFS server itself, unless the action has its
+// own authorization.
+func (c *Client) newRequest(ctx context.Context, method string, a action, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.Href, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range a.Header {
+		req.Header.Set(k, v)
+	}
+	if req.Header.Get("Authorization") == "" {
+		if endpoint, err := url.Parse(c.url); err == nil && endpoint.Host == req.URL.Host {
+			if err := c.authorize(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return req, nil
+}
+
+func (c *Client) authorize(req *http.Request) error {
+	if c.creds == nil {
+		return nil
+	}
+	username, password, err := c.creds()
+	if err != nil {
+		return err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return nil
+}
+
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
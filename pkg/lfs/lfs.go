@@ -0,0 +1,271 @@
+// Package lfs stores large files as Git LFS pointers, and transfers their content through the LFS batch API.
+package lfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AttributesFile is the name of the Git attributes file which declares LFS paths.
+const AttributesFile = ".gitattributes"
+
+// Filter selects the files stored with LFS.
+type Filter struct {
+	// Threshold in bytes above which files are stored with LFS. Zero disables the threshold.
+	Threshold int64
+	// Patterns of files stored with LFS, matched against the base name, or the slash-separated path if they contain
+	// a slash.
+	Patterns []string
+}
+
+// Match reports whether a file, by slash-separated path relative to the root of the repository, is stored with LFS.
+func (f Filter) Match(name string, size int64) bool {
+	if f.Threshold > 0 && size > f.Threshold {
+		return true
+	}
+	for _, pattern := range f.Patterns {
+		target := path.Base(name)
+		if strings.Contains(pattern, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracker replaces files with pointers in a working tree, and brings their content back.
+type Tracker struct {
+	Filter Filter
+	Store  *Store
+	// Client of the LFS server. Objects stay in the local store if nil.
+	Client *Client
+	// Uploaded is the file recording the objects already uploaded to the server, so that pushes only negotiate new
+	// ones. Every object of the store is negotiated if empty.
+	Uploaded string
+}
+
+// Clean moves the content of the files of dir selected by the filter into the store, and replaces them with pointer
+// files. prefix is the slash-separated path of dir in the repository. It returns the repository paths of the pointers.
+func (t *Tracker) Clean(dir, prefix string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(rel))
+		if info.Size() <= maxPointerSize {
+			// Already cleaned.
+			if _, err := ReadPointerFile(file); err == nil {
+				paths = append(paths, name)
+				return nil
+			}
+		}
+		if !t.Filter.Match(name, info.Size()) {
+			return nil
+		}
+		p, err := t.put(file)
+		if err != nil {
+			return errors.Wrapf(err, "failed to store %s with LFS", rel)
+		}
+		if err := os.WriteFile(file, []byte(p.String()), info.Mode().Perm()); err != nil {
+			return err
+		}
+		// Keep the modification time of the content, so that unchanged files are recognized.
+		if err := os.Chtimes(file, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+		paths = append(paths, name)
+		return nil
+	})
+	return paths, err
+}
+
+func (t *Tracker) put(file string) (Pointer, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Pointer{}, err
+	}
+	defer f.Close()
+	return t.Store.Put(f, nil)
+}
+
+// Smudge replaces the pointer files of dir with their content, downloading the objects missing from the store.
+func (t *Tracker) Smudge(ctx context.Context, dir string) error {
+	files := map[string]Pointer{}
+	var missing []Pointer
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxPointerSize {
+			return err
+		}
+		p, err := ReadPointerFile(file)
+		if err == ErrNotPointer {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		files[file] = p
+		if !t.Store.Has(p.Oid) {
+			missing = append(missing, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		if t.Client == nil {
+			return errors.Errorf("%d LFS objects are missing and no LFS server is configured", len(missing))
+		}
+		if err := t.Client.Download(ctx, t.Store, missing); err != nil {
+			return err
+		}
+	}
+	for file, p := range files {
+		if err := t.restore(file, p); err != nil {
+			return errors.Wrapf(err, "failed to restore LFS object of %s", file)
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) restore(file string, p Pointer) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	src, err := t.Store.Open(p.Oid)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// Write next to the pointer, so that it is never left half-written.
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".lfs-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.ReadFrom(src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Upload the objects of the store not uploaded yet to the LFS server. The server skips the objects it already has.
+func (t *Tracker) Upload(ctx context.Context) error {
+	if t.Client == nil {
+		return nil
+	}
+	pointers, err := t.Store.Pointers()
+	if err != nil {
+		return err
+	}
+	uploaded, err := t.readUploaded()
+	if err != nil {
+		return err
+	}
+	var pending []Pointer
+	for _, p := range pointers {
+		if !uploaded[p.Oid] {
+			pending = append(pending, p)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := t.Client.Upload(ctx, t.Store, pending); err != nil {
+		return err
+	}
+	for _, p := range pending {
+		uploaded[p.Oid] = true
+	}
+	return t.writeUploaded(uploaded)
+}
+
+// Objects recorded as uploaded to the server of the client, by oid. The record starts with the URL of the server, and
+// is ignored if it was made for another one.
+func (t *Tracker) readUploaded() (map[string]bool, error) {
+	uploaded := map[string]bool{}
+	if t.Uploaded == "" {
+		return uploaded, nil
+	}
+	data, err := os.ReadFile(t.Uploaded)
+	if os.IsNotExist(err) {
+		return uploaded, nil
+	} else if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if lines[0] != t.Client.url {
+		return uploaded, nil
+	}
+	for _, oid := range lines[1:] {
+		uploaded[oid] = true
+	}
+	return uploaded, nil
+}
+
+func (t *Tracker) writeUploaded(uploaded map[string]bool) error {
+	if t.Uploaded == "" {
+		return nil
+	}
+	oids := make([]string, 0, len(uploaded))
+	for oid := range uploaded {
+		oids = append(oids, oid)
+	}
+	sort.Strings(oids)
+	if err := os.MkdirAll(filepath.Dir(t.Uploaded), 0755); err != nil {
+		return err
+	}
+	tmp := t.Uploaded + ".tmp"
+	data := t.Client.url + "\n" + strings.Join(append(oids, ""), "\n")
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Rename(tmp, t.Uploaded), "failed to record uploaded LFS objects")
+}
+
+// Attributes renders the Git attributes marking the patterns and pointer paths as LFS files.
+func (t *Tracker) Attributes(paths []string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by notesforever.\n")
+	for _, pattern := range t.Filter.Patterns {
+		b.WriteString(escapeAttribute(pattern) + " filter=lfs diff=lfs merge=lfs -text\n")
+	}
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		if t.matchesPattern(p) {
+			continue
+		}
+		b.WriteString("/" + escapeAttribute(p) + " filter=lfs diff=lfs merge=lfs -text\n")
+	}
+	return b.String()
+}
+
+func (t *Tracker) matchesPattern(name string) bool {
+	return Filter{Patterns: t.Filter.Patterns}.Match(name, 0)
+}
+
+// Git attributes patterns cannot contain spaces; git-lfs uses a character class instead.
+func escapeAttribute(pattern string) string {
+	return strings.ReplaceAll(pattern, " ", "[[:space:]]")
+}
@@ -0,0 +1,232 @@
+package lfs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestPointer(t *testing.T) {
+	p := Pointer{Oid: strings.Repeat("ab", 32), Size: 12345}
+	assert.Check(t, is.Equal(p.String(), "version https://git-lfs.github.com/spec/v1\n"+
+		"oid sha256:"+p.Oid+"\nsize 12345\n"))
+	parsed, err := ParsePointer([]byte(p.String()))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(parsed, p))
+	_, err = ParsePointer([]byte("some note"))
+	assert.Check(t, is.ErrorIs(err, ErrNotPointer))
+	for _, oid := range []string{"../../../../etc/passwd" + strings.Repeat("a", 42), strings.Repeat("AB", 32), "ab"} {
+		_, err = ParsePointer([]byte(Pointer{Oid: oid, Size: 1}.String()))
+		assert.Check(t, is.ErrorIs(err, ErrNotPointer), oid)
+	}
+}
+
+func TestStoreInvalidOid(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "objects"))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644))
+	for _, oid := range []string{"../secret", "a", ""} {
+		assert.Check(t, !store.Has(oid), oid)
+		_, err := store.Open(oid)
+		assert.Check(t, err != nil, oid)
+	}
+}
+
+func TestUploadUnrequestedObject(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "objects"))
+	p, err := store.Put(strings.NewReader("note"), nil)
+	assert.NilError(t, err)
+	var puts int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+			return
+		}
+		// Ask for an object which was not requested, instead of the requested one.
+		obj := batchObject{batchPointer: batchPointer{Oid: "../../secret", Size: 6}, Actions: map[string]action{
+			upload: {Href: srv.URL + "/objects/secret"},
+		}}
+		w.Header().Set("Content-Type", mediaType)
+		json.NewEncoder(w).Encode(batchResponse{Objects: []batchObject{obj}})
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, func() (string, string, error) { return "", "", nil })
+	assert.NilError(t, client.Upload(context.Background(), store, []Pointer{p}))
+	assert.Check(t, is.Equal(puts, 0))
+}
+
+func TestEndpoint(t *testing.T) {
+	for remote, expected := range map[string]string{
+		"https://github.com/alice/notes.git":   "https://github.com/alice/notes.git/info/lfs",
+		"https://x:y@gitlab.com/alice/notes":   "https://gitlab.com/alice/notes.git/info/lfs",
+		"git@github.com:alice/notes.git":       "https://github.com/alice/notes.git/info/lfs",
+		"ssh://git@nas.local:22/backups/notes": "https://nas.local/backups/notes.git/info/lfs",
+	} {
+		endpoint, err := Endpoint(remote)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(endpoint, expected), remote)
+	}
+}
+
+func TestCleanUploadSmudge(t *testing.T) {
+	srv := newLFSServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	large := strings.Repeat("scan", 1000)
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "Media"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "Media", "scan.bin"), []byte(large), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "Media", "memo.m4a"), []byte("audio"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "NoteStore.sqlite"), []byte("db"), 0644))
+
+	client := NewClient(srv.URL+"/alice/notes.git/info/lfs", func() (string, string, error) {
+		return "alice", "secret", nil
+	})
+	tracker := &Tracker{
+		Filter: Filter{Threshold: 1024, Patterns: []string{"*.m4a"}},
+		Store:  NewStore(filepath.Join(t.TempDir(), "objects")),
+		Client: client,
+	}
+	paths, err := tracker.Clean(dir, "backup")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(paths, []string{"backup/Media/memo.m4a", "backup/Media/scan.bin"}))
+	p, err := ReadPointerFile(filepath.Join(dir, "Media", "scan.bin"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(p.Size, int64(len(large))))
+	_, err = ReadPointerFile(filepath.Join(dir, "NoteStore.sqlite"))
+	assert.Check(t, is.ErrorIs(err, ErrNotPointer))
+	assert.Check(t, is.Equal(tracker.Attributes(paths), "# Generated by notesforever.\n"+
+		"*.m4a filter=lfs diff=lfs merge=lfs -text\n"+
+		"/backup/Media/scan.bin filter=lfs diff=lfs merge=lfs -text\n"))
+
+	assert.NilError(t, tracker.Upload(context.Background()))
+	assert.Check(t, is.Len(srv.objects, 2))
+	// Objects already on the server are not uploaded again.
+	assert.NilError(t, tracker.Upload(context.Background()))
+	assert.Check(t, is.Equal(srv.uploads, 2))
+
+	// Rehydrate from the server, with an empty local store.
+	tracker.Store = NewStore(filepath.Join(t.TempDir(), "objects"))
+	assert.NilError(t, tracker.Smudge(context.Background(), dir))
+	content, err := os.ReadFile(filepath.Join(dir, "Media", "scan.bin"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), large))
+	content, err = os.ReadFile(filepath.Join(dir, "Media", "memo.m4a"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "audio"))
+}
+
+func TestUploadNewObjects(t *testing.T) {
+	srv := newLFSServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "a.m4a"), []byte("a"), 0644))
+	creds := func() (string, string, error) { return "alice", "secret", nil }
+	tracker := &Tracker{
+		Filter:   Filter{Patterns: []string{"*.m4a"}},
+		Store:    NewStore(filepath.Join(t.TempDir(), "objects")),
+		Client:   NewClient(srv.URL+"/alice/notes.git/info/lfs", creds),
+		Uploaded: filepath.Join(t.TempDir(), "uploaded"),
+	}
+	_, err := tracker.Clean(dir, "")
+	assert.NilError(t, err)
+	assert.NilError(t, tracker.Upload(context.Background()))
+	assert.Check(t, is.Equal(srv.negotiated, 1))
+
+	// Objects uploaded before are not negotiated again.
+	assert.NilError(t, tracker.Upload(context.Background()))
+	assert.Check(t, is.Equal(srv.negotiated, 1))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "b.m4a"), []byte("b"), 0644))
+	_, err = tracker.Clean(dir, "")
+	assert.NilError(t, err)
+	assert.NilError(t, tracker.Upload(context.Background()))
+	assert.Check(t, is.Equal(srv.negotiated, 2))
+	assert.Check(t, is.Equal(srv.uploads, 2))
+
+	// Unless the server changed.
+	tracker.Client = NewClient(srv.URL+"/alice/notes.git/info/lfs/", creds)
+	assert.NilError(t, tracker.Upload(context.Background()))
+	assert.Check(t, is.Equal(srv.negotiated, 2))
+	tracker.Client = NewClient(srv.URL+"/alice/other.git/info/lfs", creds)
+	assert.Check(t, tracker.Upload(context.Background()) != nil)
+	assert.Check(t, is.Equal(srv.negotiated, 2))
+}
+
+// Minimal LFS server implementing the batch API with the basic transfer adapter.
+type lfsServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads int
+	// Objects sent in upload batch requests.
+	negotiated int
+}
+
+func newLFSServer(t *testing.T) *lfsServer {
+	s := &lfsServer{objects: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/alice/notes.git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		assert.Check(t, is.Equal(username+":"+password, "alice:secret"))
+		assert.Check(t, is.Equal(r.Header.Get("Accept"), mediaType))
+		var req batchRequest
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := batchResponse{Transfer: "basic"}
+		s.mu.Lock()
+		if req.Operation == upload {
+			s.negotiated += len(req.Objects)
+		}
+		for _, obj := range req.Objects {
+			o := batchObject{batchPointer: obj, Actions: map[string]action{}}
+			href := s.URL + "/objects/" + obj.Oid
+			_, exists := s.objects[obj.Oid]
+			switch {
+			case req.Operation == upload && !exists:
+				o.Actions[upload] = action{Href: href, Header: map[string]string{"Authorization": "Token upload"}}
+			case req.Operation == download && exists:
+				o.Actions[download] = action{Href: href}
+			}
+			resp.Objects = append(resp.Objects, o)
+		}
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", mediaType)
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/objects/", func(w http.ResponseWriter, r *http.Request) {
+		oid := strings.TrimPrefix(r.URL.Path, "/objects/")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			assert.Check(t, is.Equal(r.Header.Get("Authorization"), "Token upload"))
+			data, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			s.objects[oid] = data
+			s.uploads++
+		case http.MethodGet:
+			username, _, _ := r.BasicAuth()
+			assert.Check(t, is.Equal(username, "alice"))
+			data, ok := s.objects[oid]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
@@ -0,0 +1,94 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	specVersion = "https://git-lfs.github.com/spec/v1"
+	oidPrefix   = "sha256:"
+	// Pointer files are tiny; anything bigger is content.
+	maxPointerSize = 1024
+)
+
+// ErrNotPointer is returned when parsing content which is not a pointer.
+var ErrNotPointer = errors.New("not an LFS pointer")
+
+// Pointer stands for an LFS object in Git, as a small text file.
+type Pointer struct {
+	// Oid is the hex SHA-256 of the content.
+	Oid  string
+	Size int64
+}
+
+// String is the content of the pointer file.
+func (p Pointer) String() string {
+	return fmt.Sprintf("version %s\noid %s%s\nsize %d\n", specVersion, oidPrefix, p.Oid, p.Size)
+}
+
+// ParsePointer parses the content of a pointer file.
+func ParsePointer(data []byte) (Pointer, error) {
+	var p Pointer
+	if len(data) > maxPointerSize || !bytes.HasPrefix(data, []byte("version "+specVersion+"\n")) {
+		return p, ErrNotPointer
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			return p, ErrNotPointer
+		}
+		switch key {
+		case "oid":
+			if !strings.HasPrefix(value, oidPrefix) {
+				return p, errors.Errorf("unsupported LFS hash %s", value)
+			}
+			p.Oid = strings.TrimPrefix(value, oidPrefix)
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return p, ErrNotPointer
+			}
+			p.Size = size
+		}
+	}
+	if !validOid(p.Oid) {
+		return p, ErrNotPointer
+	}
+	return p, nil
+}
+
+// Reports whether oid is a hex SHA-256, as written by git-lfs: 64 lowercase hex characters.
+func validOid(oid string) bool {
+	if len(oid) != 64 {
+		return false
+	}
+	for _, c := range oid {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadPointerFile parses the file at path, or returns ErrNotPointer if it is regular content.
+func ReadPointerFile(path string) (Pointer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Pointer{}, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxPointerSize+1))
+	if err != nil {
+		return Pointer{}, err
+	}
+	return ParsePointer(data)
+}
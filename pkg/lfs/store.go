@@ -0,0 +1,97 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Store of LFS objects on disk, with the layout of git-lfs: <dir>/ab/cd/abcd...
+type Store struct {
+	dir string
+}
+
+// NewStore returns the store at dir, usually .git/lfs/objects.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Path of the object oid, which must be valid: only call it for objects the store has.
+func (s *Store) Path(oid string) string {
+	return filepath.Join(s.dir, oid[0:2], oid[2:4], oid)
+}
+
+// Has reports whether the object oid is in the store. An invalid oid is never in the store.
+func (s *Store) Has(oid string) bool {
+	if !validOid(oid) {
+		return false
+	}
+	_, err := os.Stat(s.Path(oid))
+	return err == nil
+}
+
+// Open the object oid.
+func (s *Store) Open(oid string) (*os.File, error) {
+	if !validOid(oid) {
+		return nil, errors.Errorf("invalid LFS object id %q", oid)
+	}
+	return os.Open(s.Path(oid))
+}
+
+// Put the content of r in the store and return its pointer. If expected is not nil, the content must match it.
+func (s *Store) Put(r io.Reader, expected *Pointer) (Pointer, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return Pointer{}, err
+	}
+	tmp, err := os.CreateTemp(s.dir, "incomplete-")
+	if err != nil {
+		return Pointer{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Pointer{}, err
+	}
+	p := Pointer{Oid: hex.EncodeToString(h.Sum(nil)), Size: size}
+	if expected != nil && p != *expected {
+		return Pointer{}, errors.Errorf("LFS object %s does not match its content", expected.Oid)
+	}
+
+	dst := s.Path(p.Oid)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return Pointer{}, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return Pointer{}, err
+	}
+	return p, nil
+}
+
+// Pointers to every object in the store.
+func (s *Store) Pointers() ([]Pointer, error) {
+	var pointers []Pointer
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || len(info.Name()) != 64 || strings.HasPrefix(info.Name(), "incomplete-") {
+			return nil
+		}
+		pointers = append(pointers, Pointer{Oid: info.Name(), Size: info.Size()})
+		return nil
+	})
+	return pointers, err
+}
@@ -0,0 +1,229 @@
+// Package manifest records what Git does not keep about backed up files: their ownership, permissions, times and
+// extended attributes, along with the size and SHA-256 of their content.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/copy"
+	"github.com/pkg/errors"
+)
+
+// Filename of the manifest, at the root of the repository.
+const Filename = "manifest.jsonl"
+
+// Types of entries.
+const (
+	File    = "file"
+	Dir     = "dir"
+	Symlink = "symlink"
+)
+
+// Entry of a file, or a directory, in the manifest.
+type Entry struct {
+	// Path relative to the backup directory, with forward slashes.
+	Path string `json:"path"`
+	Type string `json:"type"`
+	// Size and SHA256 of the content of regular files.
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Mode holds the permission and special bits, in octal.
+	Mode  string    `json:"mode"`
+	UID   int       `json:"uid"`
+	GID   int       `json:"gid"`
+	Mtime time.Time `json:"mtime"`
+	Atime time.Time `json:"atime"`
+	// Xattrs are the extended attributes, by name. Values are base64 encoded.
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// Manifest lists entries sorted by path. It is written as JSON lines, one entry per line, so that Git diffs it well.
+type Manifest struct {
+	Entries []Entry
+}
+
+// Stat returns the entry of the file at path, without its size and hash.
+func Stat(path string) (Entry, error) {
+	md, err := copy.ReadMetadata(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{
+		Type:   File,
+		Mode:   formatMode(md.Mode),
+		UID:    md.UID,
+		GID:    md.GID,
+		Mtime:  md.Mtime.UTC(),
+		Atime:  md.Atime.UTC(),
+		Xattrs: md.Xattrs,
+	}
+	switch {
+	case md.Mode.IsDir():
+		e.Type = Dir
+	case md.Mode&os.ModeSymlink != 0:
+		e.Type = Symlink
+	case !md.Mode.IsRegular():
+		return Entry{}, errors.Errorf("unsupported file type %s for %s", md.Mode.Type(), path)
+	}
+	return e, nil
+}
+
+// Metadata of the entry, for the copy package.
+func (e Entry) Metadata() (*copy.Metadata, error) {
+	mode, err := parseMode(e.Mode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid mode of %s", e.Path)
+	}
+	switch e.Type {
+	case Dir:
+		mode |= os.ModeDir
+	case Symlink:
+		mode |= os.ModeSymlink
+	}
+	return &copy.Metadata{Mode: mode, UID: e.UID, GID: e.GID, Atime: e.Atime, Mtime: e.Mtime, Xattrs: e.Xattrs}, nil
+}
+
+// Read the manifest file at path.
+func Read(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse a manifest written by Write.
+func Parse(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, errors.Wrap(err, "invalid manifest entry")
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	m.sort()
+	return m, nil
+}
+
+// Write the manifest to path.
+func (m *Manifest) Write(path string) error {
+	m.sort()
+	var b bytes.Buffer
+	for _, e := range m.Entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// Lookup the entry of path.
+func (m *Manifest) Lookup(path string) (Entry, bool) {
+	i := sort.Search(len(m.Entries), func(i int) bool { return m.Entries[i].Path >= path })
+	if i < len(m.Entries) && m.Entries[i].Path == path {
+		return m.Entries[i], true
+	}
+	return Entry{}, false
+}
+
+// Apply the metadata of the entries to the files of dir. Entries of missing files are skipped.
+func (m *Manifest) Apply(dir string) error {
+	// Directories last, deepest first, as changing their content changes their modification time.
+	var dirs []Entry
+	for _, e := range m.Entries {
+		if e.Type == Dir {
+			dirs = append(dirs, e)
+			continue
+		}
+		if err := apply(dir, e); err != nil {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := apply(dir, dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func apply(dir string, e Entry) error {
+	if !isLocal(e.Path) {
+		return errors.Errorf("invalid path %s", e.Path)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(e.Path))
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return nil
+	}
+	md, err := e.Metadata()
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(copy.ApplyMetadata(target, md), "failed to restore metadata of %s", e.Path)
+}
+
+// Reports whether the slash-separated path name stays within the directory it is relative to.
+func isLocal(name string) bool {
+	name = path.Clean(name)
+	return name != ".." && !strings.HasPrefix(name, "../") && !path.IsAbs(name)
+}
+
+func (m *Manifest) sort() {
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+}
+
+// Unix permission and special bits of mode, in octal.
+func formatMode(mode os.FileMode) string {
+	bits := uint64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+func parseMode(s string) (os.FileMode, error) {
+	bits, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	mode := os.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
@@ -0,0 +1,29 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestApplyInvalidPath(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "restore")
+	assert.NilError(t, os.Mkdir(dir, 0755))
+	before, err := os.Stat(parent)
+	assert.NilError(t, err)
+
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"..", "../restore", "a/../..", "/tmp"} {
+		m := &Manifest{Entries: []Entry{{Path: name, Type: Dir, Mode: "700", Mtime: mtime, Atime: mtime}}}
+		assert.Check(t, m.Apply(dir) != nil, name)
+	}
+	after, err := os.Stat(parent)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(after.Mode(), before.Mode()))
+	assert.Check(t, after.ModTime().Equal(before.ModTime()))
+}
@@ -0,0 +1,377 @@
+package notestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Document is the content of a note: its text, split into runs of text sharing the same attributes.
+type Document struct {
+	Text string
+	Runs []Run
+}
+
+// Run of text sharing the same attributes. The attributes of a paragraph are those of the runs ending it.
+type Run struct {
+	Text          string
+	Paragraph     Paragraph
+	Font          Font
+	Bold          bool
+	Italic        bool
+	Underline     bool
+	Strikethrough bool
+	// Superscript is positive for superscript, and negative for subscript.
+	Superscript int
+	Link        string
+	// Color of the text, or nil for the default one.
+	Color *Color
+	// Attachment anchored at the run, whose text is the object replacement character U+FFFC, or nil.
+	Attachment *AttachmentRef
+}
+
+// Paragraph attributes of a run.
+type Paragraph struct {
+	Style     Style
+	Alignment Alignment
+	// Indent is the level of indentation, of lists in particular, from 0.
+	Indent     int
+	BlockQuote bool
+	// Checked is set for done items of checklists.
+	Checked bool
+}
+
+// Style of a paragraph, as numbered by Notes.
+type Style int
+
+// Styles of paragraphs.
+const (
+	StyleBody         Style = -1
+	StyleTitle        Style = 0
+	StyleHeading      Style = 1
+	StyleSubheading   Style = 2
+	StyleMonospaced   Style = 4
+	StyleDottedList   Style = 100
+	StyleDashedList   Style = 101
+	StyleNumberedList Style = 102
+	StyleChecklist    Style = 103
+)
+
+// IsList is true for the styles of list items.
+func (s Style) IsList() bool {
+	return s >= StyleDottedList && s <= StyleChecklist
+}
+
+// Alignment of a paragraph, as numbered by Notes.
+type Alignment int
+
+// Alignments of paragraphs.
+const (
+	AlignLeft      Alignment = 0
+	AlignCenter    Alignment = 1
+	AlignRight     Alignment = 2
+	AlignJustified Alignment = 3
+)
+
+// Font of a run, whose fields are empty for the default font.
+type Font struct {
+	Name string
+	// Size in points.
+	Size float32
+}
+
+// Color of a run, with components from 0 to 1.
+type Color struct {
+	Red, Green, Blue, Alpha float32
+}
+
+// AttachmentRef refers to an attachment of the note from its content.
+type AttachmentRef struct {
+	// Identifier of the attachment, as Attachment.Identifier.
+	Identifier string
+	TypeUTI    string
+}
+
+// Fields of the protobuf messages of note contents.
+const (
+	// NoteStoreProto
+	fieldDocument protowire.Number = 2
+	// Document
+	fieldNote protowire.Number = 3
+	// Note
+	fieldNoteText     protowire.Number = 2
+	fieldAttributeRun protowire.Number = 5
+	// AttributeRun
+	fieldLength         protowire.Number = 1
+	fieldParagraphStyle protowire.Number = 2
+	fieldFont           protowire.Number = 3
+	fieldFontWeight     protowire.Number = 5
+	fieldUnderlined     protowire.Number = 6
+	fieldStrikethrough  protowire.Number = 7
+	fieldSuperscript    protowire.Number = 8
+	fieldLink           protowire.Number = 9
+	fieldColor          protowire.Number = 10
+	fieldAttachmentInfo protowire.Number = 12
+	// ParagraphStyle
+	fieldStyleType  protowire.Number = 1
+	fieldAlignment  protowire.Number = 2
+	fieldIndent     protowire.Number = 4
+	fieldChecklist  protowire.Number = 5
+	fieldBlockQuote protowire.Number = 8
+	// Checklist
+	fieldChecklistDone protowire.Number = 2
+	// Font
+	fieldFontName  protowire.Number = 1
+	fieldPointSize protowire.Number = 2
+	// Color
+	fieldRed   protowire.Number = 1
+	fieldGreen protowire.Number = 2
+	fieldBlue  protowire.Number = 3
+	fieldAlpha protowire.Number = 4
+	// AttachmentInfo
+	fieldAttachmentIdentifier protowire.Number = 1
+	fieldTypeUTI              protowire.Number = 2
+)
+
+// Font weights of runs.
+const (
+	fontWeightBold       = 1
+	fontWeightItalic     = 2
+	fontWeightBoldItalic = 3
+)
+
+// Document reads and decodes the content of the note with ID id. It returns nil for a note without content.
+func (s *Store) Document(id int64) (*Document, error) {
+	data, err := s.NoteData(id)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	doc, err := Decode(data)
+	return doc, errors.Wrapf(err, "failed to decode note %d", id)
+}
+
+// Decode the content of a note, as stored in the database: gzip-compressed protobuf. Uncompressed protobuf is
+// decoded too. The content of locked notes is encrypted, and cannot be decoded.
+func Decode(data []byte) (*Document, error) {
+	data, err := gunzip(data)
+	if err != nil {
+		return nil, err
+	}
+	var doc *Document
+	err = walk(data, func(num protowire.Number, f field) error {
+		if num != fieldDocument {
+			return nil
+		}
+		return walk(f.bytes, func(num protowire.Number, f field) error {
+			if num != fieldNote {
+				return nil
+			}
+			var err error
+			doc, err = decodeNote(f.bytes)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid note")
+	}
+	if doc == nil {
+		return &Document{}, nil
+	}
+	return doc, nil
+}
+
+// Decompress gzip-compressed data, and return other data as it is.
+func gunzip(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	data, err = io.ReadAll(r)
+	return data, errors.Wrap(err, "failed to decompress")
+}
+
+// Decode a Note message: text and attribute runs, of a note or of a cell of a table.
+func decodeNote(data []byte) (*Document, error) {
+	var text string
+	var runs []Run
+	var lengths []int
+	err := walk(data, func(num protowire.Number, f field) error {
+		switch num {
+		case fieldNoteText:
+			text = string(f.bytes)
+		case fieldAttributeRun:
+			run, length, err := decodeRun(f.bytes)
+			if err != nil {
+				return errors.Wrap(err, "invalid attribute run")
+			}
+			runs = append(runs, run)
+			lengths = append(lengths, length)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Lengths count UTF-16 code units, as the strings of Notes.
+	units := utf16.Encode([]rune(text))
+	doc := &Document{Text: text}
+	offset := 0
+	for i, run := range runs {
+		end := offset + lengths[i]
+		if end > len(units) {
+			end = len(units)
+		}
+		if end <= offset {
+			continue
+		}
+		run.Text = string(utf16.Decode(units[offset:end]))
+		doc.Runs = append(doc.Runs, run)
+		offset = end
+	}
+	if offset < len(units) {
+		doc.Runs = append(doc.Runs, Run{Text: string(utf16.Decode(units[offset:])), Paragraph: Paragraph{Style: StyleBody}})
+	}
+	return doc, nil
+}
+
+func decodeRun(data []byte) (Run, int, error) {
+	run := Run{Paragraph: Paragraph{Style: StyleBody}}
+	length := 0
+	err := walk(data, func(num protowire.Number, f field) error {
+		switch num {
+		case fieldLength:
+			length = int(f.int32())
+		case fieldParagraphStyle:
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				switch num {
+				case fieldStyleType:
+					run.Paragraph.Style = Style(f.int32())
+				case fieldAlignment:
+					run.Paragraph.Alignment = Alignment(f.int32())
+				case fieldIndent:
+					run.Paragraph.Indent = int(f.int32())
+				case fieldBlockQuote:
+					run.Paragraph.BlockQuote = f.int32() != 0
+				case fieldChecklist:
+					return walk(f.bytes, func(num protowire.Number, f field) error {
+						if num == fieldChecklistDone {
+							run.Paragraph.Checked = f.int32() != 0
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		case fieldFont:
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				switch num {
+				case fieldFontName:
+					run.Font.Name = string(f.bytes)
+				case fieldPointSize:
+					run.Font.Size = f.float32()
+				}
+				return nil
+			})
+		case fieldFontWeight:
+			switch f.int32() {
+			case fontWeightBold:
+				run.Bold = true
+			case fontWeightItalic:
+				run.Italic = true
+			case fontWeightBoldItalic:
+				run.Bold, run.Italic = true, true
+			}
+		case fieldUnderlined:
+			run.Underline = f.int32() != 0
+		case fieldStrikethrough:
+			run.Strikethrough = f.int32() != 0
+		case fieldSuperscript:
+			run.Superscript = int(f.int32())
+		case fieldLink:
+			run.Link = string(f.bytes)
+		case fieldColor:
+			run.Color = &Color{}
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				switch num {
+				case fieldRed:
+					run.Color.Red = f.float32()
+				case fieldGreen:
+					run.Color.Green = f.float32()
+				case fieldBlue:
+					run.Color.Blue = f.float32()
+				case fieldAlpha:
+					run.Color.Alpha = f.float32()
+				}
+				return nil
+			})
+		case fieldAttachmentInfo:
+			run.Attachment = &AttachmentRef{}
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				switch num {
+				case fieldAttachmentIdentifier:
+					run.Attachment.Identifier = string(f.bytes)
+				case fieldTypeUTI:
+					run.Attachment.TypeUTI = string(f.bytes)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return run, length, err
+}
+
+// Value of a protobuf field, of one of the wire types.
+type field struct {
+	typ     protowire.Type
+	varint  uint64
+	fixed32 uint32
+	bytes   []byte
+}
+
+func (f field) int32() int32 {
+	return int32(f.varint)
+}
+
+func (f field) float32() float32 {
+	return math.Float32frombits(f.fixed32)
+}
+
+// Call fn with each field of the protobuf message data, in order. Fields of unknown wire types are skipped.
+func walk(data []byte, fn func(protowire.Number, field) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		f := field{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			f.fixed32, n = protowire.ConsumeFixed32(data)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := fn(num, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
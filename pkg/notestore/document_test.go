@@ -0,0 +1,123 @@
+package notestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NilError(t, err)
+	return data
+}
+
+func TestDecodeFormatted(t *testing.T) {
+	doc, err := Decode(readFixture(t, "formatted.pb.gz"))
+	assert.NilError(t, err)
+	body := func(text string) Run { return Run{Text: text, Paragraph: Paragraph{Style: StyleBody}} }
+	para := func(text string, p Paragraph) Run { return Run{Text: text, Paragraph: p} }
+	assert.Check(t, is.DeepEqual(doc.Runs, []Run{
+		para("Chocolate cake\n", Paragraph{Style: StyleTitle}),
+		para("Ingredients\n", Paragraph{Style: StyleHeading}),
+		{Text: "Dark", Paragraph: Paragraph{Style: StyleBody}, Bold: true},
+		body(" chocolate, "),
+		{Text: "butter", Paragraph: Paragraph{Style: StyleBody}, Italic: true},
+		body(", "),
+		{Text: "sugar", Paragraph: Paragraph{Style: StyleBody}, Bold: true, Italic: true},
+		body(", "),
+		{Text: "eggs", Paragraph: Paragraph{Style: StyleBody}, Underline: true},
+		body(", "),
+		{Text: "flour", Paragraph: Paragraph{Style: StyleBody}, Strikethrough: true},
+		body(" 🥚\n"),
+		para("Steps\n", Paragraph{Style: StyleSubheading}),
+		para("Melt\n", Paragraph{Style: StyleNumberedList}),
+		para("Gently\n", Paragraph{Style: StyleNumberedList, Indent: 1}),
+		para("Bake\n", Paragraph{Style: StyleNumberedList}),
+		para("Buy cocoa\n", Paragraph{Style: StyleChecklist, Checked: true}),
+		para("Buy cream\n", Paragraph{Style: StyleChecklist}),
+		para("Dots\n", Paragraph{Style: StyleDottedList}),
+		para("Dashes\n", Paragraph{Style: StyleDashedList}),
+		para("oven.temp = 180\n", Paragraph{Style: StyleMonospaced}),
+		para("Let it cool.\n", Paragraph{Style: StyleBody, BlockQuote: true}),
+		{Text: "Recipe", Paragraph: Paragraph{Style: StyleBody}, Link: "https://example.com/cake"},
+		body(" from "),
+		{Text: "Grandma", Paragraph: Paragraph{Style: StyleBody}, Font: Font{Name: "Noteworthy-Bold", Size: 18},
+			Color: &Color{Red: 1, Alpha: 1}},
+		body(" H"),
+		{Text: "2", Paragraph: Paragraph{Style: StyleBody}, Superscript: -1},
+		body("O\n"),
+		{Text: "\ufffc", Paragraph: Paragraph{Style: StyleBody},
+			Attachment: &AttachmentRef{Identifier: "ATTACHMENT-PHOTO", TypeUTI: "public.jpeg"}},
+		body("\n"),
+		{Text: "\ufffc", Paragraph: Paragraph{Style: StyleBody},
+			Attachment: &AttachmentRef{Identifier: "ATTACHMENT-LINK", TypeUTI: "public.url"}},
+		body("\n"),
+		{Text: "\ufffc", Paragraph: Paragraph{Style: StyleBody},
+			Attachment: &AttachmentRef{Identifier: "ATTACHMENT-TABLE", TypeUTI: TableTypeUTI}},
+		body("\n"),
+	}))
+
+	var text string
+	for _, r := range doc.Runs {
+		text += r.Text
+	}
+	assert.Check(t, is.Equal(text, doc.Text))
+	assert.Check(t, StyleChecklist.IsList())
+	assert.Check(t, !StyleMonospaced.IsList())
+}
+
+func TestDecodeUncompressed(t *testing.T) {
+	r, err := gzip.NewReader(bytes.NewReader(readFixture(t, "simple.pb.gz")))
+	assert.NilError(t, err)
+	data, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	doc, err := Decode(data)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(doc.Text, "Shopping list\nMilk, eggs\n"))
+	assert.Check(t, is.Len(doc.Runs, 2))
+
+}
+
+func TestDecodeWithoutRuns(t *testing.T) {
+	note := protowire.AppendTag(nil, fieldNoteText, protowire.BytesType)
+	note = protowire.AppendString(note, "Hello")
+	document := protowire.AppendTag(nil, fieldNote, protowire.BytesType)
+	document = protowire.AppendBytes(document, note)
+	data := protowire.AppendTag(nil, fieldDocument, protowire.BytesType)
+	data = protowire.AppendBytes(data, document)
+
+	// Text not covered by runs is body text.
+	doc, err := Decode(data)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(doc.Runs, []Run{{Text: "Hello", Paragraph: Paragraph{Style: StyleBody}}}))
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte{0x1f, 0x8b, 0x08})
+	assert.Check(t, err != nil)
+	_, err = Decode([]byte{0x12, 0x05, 0x01})
+	assert.Check(t, err != nil)
+}
+
+func TestStoreDocument(t *testing.T) {
+	s := open(t, "modern.sqlite")
+	doc, err := s.Document(20)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(doc.Runs, []Run{
+		{Text: "Shopping list\n", Paragraph: Paragraph{Style: StyleTitle}},
+		{Text: "Milk, eggs\n", Paragraph: Paragraph{Style: StyleBody}},
+	}))
+
+	doc, err = s.Document(22)
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(doc))
+}
@@ -0,0 +1,324 @@
+// Package notestore reads the accounts, folders and notes of NoteStore.sqlite, the database of the Notes app. The
+// database is opened read-only, and must not be written to while open: read a snapshot of it, as backups make.
+package notestore
+
+import (
+	"database/sql"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+	"github.com/pkg/errors"
+)
+
+// Filename of the database, at the root of the Notes container.
+const Filename = "NoteStore.sqlite"
+
+// Account holding folders, e.g. iCloud or On My Mac.
+type Account struct {
+	ID         int64
+	Identifier string
+	Name       string
+}
+
+// Folder of notes.
+type Folder struct {
+	ID         int64
+	Identifier string
+	Title      string
+	AccountID  int64
+	// ParentID is the ID of the parent folder, or 0 for a top-level folder.
+	ParentID int64
+	// Path holds the titles of the ancestors of the folder, from the top-level one, and its own title.
+	Path []string
+	// Trash is true for the Recently Deleted folder.
+	Trash bool
+}
+
+// Note in a folder. The content of the note is read with Store.NoteData.
+type Note struct {
+	ID         int64
+	Identifier string
+	Title      string
+	Snippet    string
+	FolderID   int64
+	AccountID  int64
+	Created    time.Time
+	Modified   time.Time
+	Pinned     bool
+	// Locked notes are encrypted with a password.
+	Locked      bool
+	Attachments []Attachment
+}
+
+// Attachment of a note, such as an image, a scan or a link.
+type Attachment struct {
+	ID         int64
+	Identifier string
+	// TypeUTI is the uniform type identifier of the attachment, e.g. "public.jpeg".
+	TypeUTI string
+	// MediaIdentifier and Filename locate the file of the attachment in the Notes container, if it has one.
+	MediaIdentifier string
+	Filename        string
+	// URL of a link attachment.
+	URL string
+}
+
+// Store is an open Notes database.
+type Store struct {
+	db *sql.DB
+	// Columns of the object table, which vary across versions of Notes.
+	columns map[string]bool
+	// Entity numbers, by name.
+	entities map[string]int64
+}
+
+// Columns of folders referring to their account, across versions of Notes.
+var folderAccountColumns = []string{"ZOWNER", "ZACCOUNT", "ZACCOUNT2", "ZACCOUNT3"}
+
+// Core Data timestamps count seconds since this date.
+var coreDataEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Open the Notes database at path, read-only.
+func Open(path string) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sqlite.OpenImmutable(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, columns: map[string]bool{}, entities: map[string]int64{}}
+	if err := s.readSchema(); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "failed to read Notes database %s", path)
+	}
+	return s, nil
+}
+
+// Close the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) readSchema() error {
+	rows, err := s.db.Query("SELECT Z_ENT, Z_NAME FROM Z_PRIMARYKEY")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ent int64
+		var name string
+		if err := rows.Scan(&ent, &name); err != nil {
+			return err
+		}
+		s.entities[name] = ent
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, name := range []string{"ICAccount", "ICFolder", "ICNote"} {
+		if _, ok := s.entities[name]; !ok {
+			return errors.Errorf("no %s entity", name)
+		}
+	}
+
+	cols, err := s.db.Query("SELECT name FROM pragma_table_info('ZICCLOUDSYNCINGOBJECT')")
+	if err != nil {
+		return err
+	}
+	defer cols.Close()
+	for cols.Next() {
+		var name string
+		if err := cols.Scan(&name); err != nil {
+			return err
+		}
+		s.columns[name] = true
+	}
+	return cols.Err()
+}
+
+// Expression of the first non-null column of the object o among candidates which exist, or NULL if none does.
+func (s *Store) column(candidates ...string) string {
+	return s.columnOf("o", candidates...)
+}
+
+// Expression of the first non-null column of the object alias among candidates which exist, or NULL if none does.
+func (s *Store) columnOf(alias string, candidates ...string) string {
+	var existing []string
+	for _, c := range candidates {
+		if s.columns[c] {
+			existing = append(existing, alias+"."+c)
+		}
+	}
+	switch len(existing) {
+	case 0:
+		return "NULL"
+	case 1:
+		return existing[0]
+	default:
+		return "COALESCE(" + strings.Join(existing, ", ") + ")"
+	}
+}
+
+// Condition selecting the live objects of entity name.
+func (s *Store) live(name string) string {
+	cond := "o.Z_ENT = " + strconv.FormatInt(s.entities[name], 10)
+	if s.columns["ZMARKEDFORDELETION"] {
+		cond += " AND IFNULL(o.ZMARKEDFORDELETION, 0) = 0"
+	}
+	return cond
+}
+
+// Accounts of the database, by ID.
+func (s *Store) Accounts() ([]Account, error) {
+	rows, err := s.db.Query("SELECT o.Z_PK, IFNULL(o.ZIDENTIFIER, ''), IFNULL(" + s.column("ZNAME") + ", '') " +
+		"FROM ZICCLOUDSYNCINGOBJECT o WHERE " + s.live("ICAccount") + " ORDER BY o.Z_PK")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var accounts []Account
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.ID, &a.Identifier, &a.Name); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// Folders of the database, sorted by path.
+func (s *Store) Folders() ([]Folder, error) {
+	rows, err := s.db.Query("SELECT o.Z_PK, IFNULL(o.ZIDENTIFIER, ''), IFNULL(" + s.column("ZTITLE2", "ZTITLE") + ", ''), " +
+		"IFNULL(" + s.column(folderAccountColumns...) + ", 0), IFNULL(" + s.column("ZPARENT") + ", 0), " +
+		"IFNULL(" + s.column("ZFOLDERTYPE") + ", 0) " +
+		"FROM ZICCLOUDSYNCINGOBJECT o WHERE " + s.live("ICFolder"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byID := map[int64]*Folder{}
+	var folders []*Folder
+	for rows.Next() {
+		var f Folder
+		var folderType int64
+		if err := rows.Scan(&f.ID, &f.Identifier, &f.Title, &f.AccountID, &f.ParentID, &folderType); err != nil {
+			return nil, err
+		}
+		f.Trash = folderType == 1
+		byID[f.ID] = &f
+		folders = append(folders, &f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]Folder, 0, len(folders))
+	for _, f := range folders {
+		f.Path = []string{f.Title}
+		// Bounded, in case of a cycle.
+		for parent, depth := byID[f.ParentID], 0; parent != nil && depth < len(folders); parent, depth = byID[parent.ParentID], depth+1 {
+			f.Path = append([]string{parent.Title}, f.Path...)
+		}
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].AccountID != result[j].AccountID {
+			return result[i].AccountID < result[j].AccountID
+		}
+		return strings.Join(result[i].Path, "/") < strings.Join(result[j].Path, "/")
+	})
+	return result, nil
+}
+
+// Notes of the database, with their attachments, sorted by ID.
+func (s *Store) Notes() ([]Note, error) {
+	rows, err := s.db.Query("SELECT o.Z_PK, IFNULL(o.ZIDENTIFIER, ''), IFNULL(" + s.column("ZTITLE1", "ZTITLE") + ", ''), " +
+		"IFNULL(" + s.column("ZSNIPPET") + ", ''), IFNULL(o.ZFOLDER, 0), IFNULL(" + s.columnOf("f", folderAccountColumns...) + ", 0), " +
+		s.column("ZCREATIONDATE3", "ZCREATIONDATE1", "ZCREATIONDATE") + ", " +
+		s.column("ZMODIFICATIONDATE1", "ZMODIFICATIONDATE") + ", " +
+		"IFNULL(" + s.column("ZISPINNED") + ", 0), IFNULL(" + s.column("ZISPASSWORDPROTECTED") + ", 0) " +
+		"FROM ZICCLOUDSYNCINGOBJECT o LEFT JOIN ZICCLOUDSYNCINGOBJECT f ON f.Z_PK = o.ZFOLDER " +
+		"WHERE " + s.live("ICNote") + " ORDER BY o.Z_PK")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notes []Note
+	byID := map[int64]int{}
+	for rows.Next() {
+		var n Note
+		var created, modified sql.NullFloat64
+		if err := rows.Scan(&n.ID, &n.Identifier, &n.Title, &n.Snippet, &n.FolderID, &n.AccountID,
+			&created, &modified, &n.Pinned, &n.Locked); err != nil {
+			return nil, err
+		}
+		n.Created = coreDataTime(created)
+		n.Modified = coreDataTime(modified)
+		byID[n.ID] = len(notes)
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.attachments()
+	if err != nil {
+		return nil, err
+	}
+	for noteID, list := range attachments {
+		if i, ok := byID[noteID]; ok {
+			notes[i].Attachments = list
+		}
+	}
+	return notes, nil
+}
+
+// Attachments by note ID, sorted by ID.
+func (s *Store) attachments() (map[int64][]Attachment, error) {
+	if _, ok := s.entities["ICAttachment"]; !ok || !s.columns["ZNOTE"] {
+		return nil, nil
+	}
+	rows, err := s.db.Query("SELECT o.Z_PK, o.ZNOTE, IFNULL(o.ZIDENTIFIER, ''), IFNULL(" + s.column("ZTYPEUTI") + ", ''), " +
+		"IFNULL(m.ZIDENTIFIER, ''), IFNULL(" + s.columnOf("m", "ZFILENAME") + ", ''), IFNULL(" + s.column("ZURLSTRING") + ", '') " +
+		"FROM ZICCLOUDSYNCINGOBJECT o LEFT JOIN ZICCLOUDSYNCINGOBJECT m ON m.Z_PK = " + s.column("ZMEDIA") + " " +
+		"WHERE " + s.live("ICAttachment") + " ORDER BY o.Z_PK")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := map[int64][]Attachment{}
+	for rows.Next() {
+		var a Attachment
+		var noteID int64
+		if err := rows.Scan(&a.ID, &noteID, &a.Identifier, &a.TypeUTI, &a.MediaIdentifier, &a.Filename, &a.URL); err != nil {
+			return nil, err
+		}
+		attachments[noteID] = append(attachments[noteID], a)
+	}
+	return attachments, rows.Err()
+}
+
+// NoteData returns the content of the note with ID id, as stored: gzip-compressed protobuf. It returns nil for a
+// note without content.
+func (s *Store) NoteData(id int64) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow("SELECT ZDATA FROM ZICNOTEDATA WHERE ZNOTE = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+func coreDataTime(t sql.NullFloat64) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return coreDataEpoch.Add(time.Duration(t.Float64 * float64(time.Second))).UTC()
+}
@@ -0,0 +1,93 @@
+package notestore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func open(t *testing.T, name string) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join("testdata", name))
+	assert.NilError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestModern(t *testing.T) {
+	s := open(t, "modern.sqlite")
+	accounts, err := s.Accounts()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(accounts, []Account{
+		{ID: 1, Identifier: "A1B2C3D4-ICLOUD", Name: "iCloud"},
+		{ID: 2, Identifier: "LocalAccount", Name: "On My Mac"},
+	}))
+
+	folders, err := s.Folders()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(folders, []Folder{
+		{ID: 10, Identifier: "FOLDER-NOTES", Title: "Notes", AccountID: 1, Path: []string{"Notes"}},
+		{ID: 13, Identifier: "FOLDER-TRASH", Title: "Recently Deleted", AccountID: 1, Path: []string{"Recently Deleted"}, Trash: true},
+		{ID: 11, Identifier: "FOLDER-RECIPES", Title: "Recipes", AccountID: 1, Path: []string{"Recipes"}},
+		{ID: 12, Identifier: "FOLDER-DESSERTS", Title: "Desserts", AccountID: 1, ParentID: 11, Path: []string{"Recipes", "Desserts"}},
+		{ID: 14, Identifier: "FOLDER-LOCAL", Title: "Notes", AccountID: 2, Path: []string{"Notes"}},
+	}))
+
+	notes, err := s.Notes()
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(notes, 4))
+	assert.Check(t, is.DeepEqual(notes[0], Note{
+		ID:         20,
+		Identifier: "NOTE-SHOPPING",
+		Title:      "Shopping list",
+		Snippet:    "Milk, eggs",
+		FolderID:   10,
+		AccountID:  1,
+		Created:    time.Date(2022, 1, 2, 10, 0, 0, 0, time.UTC),
+		Modified:   time.Date(2023, 3, 4, 12, 30, 0, 0, time.UTC),
+		Pinned:     true,
+	}))
+	assert.Check(t, is.DeepEqual(notes[1].Attachments, []Attachment{
+		{ID: 30, Identifier: "ATTACHMENT-PHOTO", TypeUTI: "public.jpeg", MediaIdentifier: "MEDIA-PHOTO", Filename: "cake.jpeg"},
+		{ID: 31, Identifier: "ATTACHMENT-LINK", TypeUTI: "public.url", URL: "https://example.com/cocoa"},
+		{ID: 32, Identifier: "ATTACHMENT-TABLE", TypeUTI: TableTypeUTI},
+	}))
+	assert.Check(t, is.Equal(notes[1].Modified, time.Date(2023, 5, 6, 18, 45, 30, 0, time.UTC)))
+	assert.Check(t, notes[2].Locked)
+	assert.Check(t, is.Equal(notes[2].AccountID, int64(2)))
+	assert.Check(t, is.Equal(notes[3].FolderID, int64(13)))
+
+	data, err := s.NoteData(99)
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(data))
+}
+
+func TestLegacy(t *testing.T) {
+	s := open(t, "legacy.sqlite")
+	folders, err := s.Folders()
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(folders, 2))
+	assert.Check(t, is.DeepEqual(folders[1].Path, []string{"Notes", "Work"}))
+	assert.Check(t, is.Equal(folders[1].AccountID, int64(1)))
+
+	notes, err := s.Notes()
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(notes, 2))
+	assert.Check(t, is.Equal(notes[0].Title, "Meeting"))
+	assert.Check(t, is.Equal(notes[0].Created, time.Date(2017, 4, 5, 6, 7, 8, 0, time.UTC)))
+	assert.Check(t, is.Equal(notes[0].Modified, time.Date(2018, 9, 10, 11, 12, 13, 0, time.UTC)))
+	assert.Check(t, !notes[0].Pinned)
+	assert.Check(t, is.DeepEqual(notes[1].Attachments, []Attachment{
+		{ID: 11, Identifier: "ATTACHMENT-SCAN", TypeUTI: "com.adobe.pdf", MediaIdentifier: "MEDIA-SCAN", Filename: "scan.pdf"},
+	}))
+}
+
+func TestOpenInvalid(t *testing.T) {
+	_, err := Open(filepath.Join("testdata", "missing.sqlite"))
+	assert.Check(t, err != nil)
+	_, err = Open(filepath.Join("testdata", "legacy.sql"))
+	assert.Check(t, err != nil)
+}
@@ -0,0 +1,361 @@
+package notestore
+
+import (
+	"bytes"
+	"database/sql"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// TableTypeUTI is the uniform type identifier of table attachments.
+const TableTypeUTI = "com.apple.notes.table"
+
+// Table of a table attachment.
+type Table struct {
+	// Cells by row, then by column. Empty cells have an empty document.
+	Cells [][]Document
+}
+
+// Fields of the protobuf messages of mergeable data, the conflict-free replicated data types of tables.
+const (
+	// MergableDataProto
+	fieldMergableDataObject protowire.Number = 2
+	// MergableDataObject
+	fieldObjectData protowire.Number = 3
+	// MergeableDataObjectData
+	fieldEntry    protowire.Number = 3
+	fieldKeyItem  protowire.Number = 4
+	fieldTypeItem protowire.Number = 5
+	fieldUUIDItem protowire.Number = 6
+	// MergeableDataObjectEntry
+	fieldEntryDictionary protowire.Number = 6
+	fieldEntryNote       protowire.Number = 10
+	fieldEntryCustomMap  protowire.Number = 13
+	fieldEntryOrderedSet protowire.Number = 16
+	// Dictionary
+	fieldDictionaryElement protowire.Number = 1
+	// DictionaryElement, MapEntry
+	fieldElementKey   protowire.Number = 1
+	fieldElementValue protowire.Number = 2
+	// ObjectID
+	fieldUnsignedIntegerValue protowire.Number = 2
+	fieldObjectIndex          protowire.Number = 6
+	// MergeableDataObjectMap
+	fieldMapType  protowire.Number = 1
+	fieldMapEntry protowire.Number = 3
+	// OrderedSet
+	fieldOrdering protowire.Number = 1
+	// OrderedSetOrdering
+	fieldOrderingArray    protowire.Number = 1
+	fieldOrderingContents protowire.Number = 2
+	// OrderedSetOrderingArray
+	fieldArrayAttachment protowire.Number = 2
+	// OrderedSetOrderingArrayAttachment
+	fieldAttachmentUUID protowire.Number = 2
+)
+
+// Keys and type of the objects of a table.
+const (
+	tableType  = "com.apple.notes.ICTable"
+	keyRows    = "crRows"
+	keyColumns = "crColumns"
+	keyCells   = "cellColumns"
+)
+
+// Reference to another object of mergeable data, or an integer.
+type objectID struct {
+	index  int
+	number uint64
+}
+
+type element struct {
+	key   int
+	value objectID
+}
+
+// Object of mergeable data. Only the fields tables use are decoded.
+type object struct {
+	// Map, of type mapType: its keys are indexes of key items.
+	mapType int
+	mapping []element
+	// Dictionary, whose keys are object indexes.
+	dictionary []element
+	// UUIDs of an ordered set, in order, and the dictionary mapping the objects of the set to their UUID object.
+	uuids    [][]byte
+	contents []element
+	// Note of a cell, encoded.
+	note []byte
+}
+
+// Table reads and decodes the table of the attachment with ID id, or returns nil if it has none.
+func (s *Store) Table(id int64) (*Table, error) {
+	column := s.column("ZMERGEABLEDATA1", "ZMERGEABLEDATA")
+	if column == "NULL" {
+		return nil, nil
+	}
+	var data []byte
+	err := s.db.QueryRow("SELECT "+column+" FROM ZICCLOUDSYNCINGOBJECT o WHERE o.Z_PK = ?", id).Scan(&data)
+	if err == sql.ErrNoRows || data == nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	table, err := DecodeTable(data)
+	return table, errors.Wrapf(err, "failed to decode table of attachment %d", id)
+}
+
+// DecodeTable decodes the mergeable data of a table attachment, gzip-compressed protobuf as stored in the database.
+func DecodeTable(data []byte) (*Table, error) {
+	data, err := gunzip(data)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	var keys, types []string
+	var uuids [][]byte
+	err = walk(data, func(num protowire.Number, f field) error {
+		if num != fieldMergableDataObject {
+			return nil
+		}
+		return walk(f.bytes, func(num protowire.Number, f field) error {
+			if num != fieldObjectData {
+				return nil
+			}
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				switch num {
+				case fieldEntry:
+					o, err := decodeObject(f.bytes)
+					if err != nil {
+						return err
+					}
+					objects = append(objects, o)
+				case fieldKeyItem:
+					keys = append(keys, string(f.bytes))
+				case fieldTypeItem:
+					types = append(types, string(f.bytes))
+				case fieldUUIDItem:
+					uuids = append(uuids, f.bytes)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid table")
+	}
+	t := &tableDecoder{objects: objects, keys: keys, uuids: uuids}
+	for _, o := range objects {
+		if o.mapType >= 0 && o.mapType < len(types) && types[o.mapType] == tableType && o.mapping != nil {
+			return t.decode(o)
+		}
+	}
+	return nil, errors.New("invalid table: no table object")
+}
+
+type tableDecoder struct {
+	objects []object
+	keys    []string
+	uuids   [][]byte
+}
+
+func (t *tableDecoder) decode(root object) (*Table, error) {
+	// Objects referred to by key, or -1 if missing.
+	refs := map[string]int{keyRows: -1, keyColumns: -1, keyCells: -1}
+	for _, e := range root.mapping {
+		if e.key >= 0 && e.key < len(t.keys) {
+			refs[t.keys[e.key]] = e.value.index
+		}
+	}
+	rows, err := t.order(refs[keyRows])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid table rows")
+	}
+	columns, err := t.order(refs[keyColumns])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid table columns")
+	}
+	table := &Table{Cells: make([][]Document, len(rows))}
+	for i := range table.Cells {
+		table.Cells[i] = make([]Document, len(columns))
+	}
+
+	cells, err := t.object(refs[keyCells])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid table cells")
+	}
+	// Cells are in a dictionary of columns, each a dictionary of rows.
+	for _, column := range cells.dictionary {
+		c, ok := columns[t.uuidIndex(column.key)]
+		if !ok {
+			continue
+		}
+		rowsOfColumn, err := t.object(column.value.index)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid table column")
+		}
+		for _, row := range rowsOfColumn.dictionary {
+			r, ok := rows[t.uuidIndex(row.key)]
+			if !ok {
+				continue
+			}
+			cell, err := t.object(row.value.index)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid table cell")
+			}
+			doc, err := decodeNote(cell.note)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid table cell")
+			}
+			table.Cells[r][c] = *doc
+		}
+	}
+	return table, nil
+}
+
+// Positions of the rows or columns of the ordered set at index, by UUID index.
+func (t *tableDecoder) order(index int) (map[int]int, error) {
+	set, err := t.object(index)
+	if err != nil {
+		return nil, err
+	}
+	positions := map[int]int{}
+	for i, uuid := range set.uuids {
+		for j, u := range t.uuids {
+			if bytes.Equal(u, uuid) {
+				positions[j] = i
+				break
+			}
+		}
+	}
+	// Objects of the set refer to the UUID of their position.
+	for _, e := range set.contents {
+		if p, ok := positions[t.uuidIndex(e.key)]; ok {
+			positions[t.uuidIndex(e.value.index)] = p
+		}
+	}
+	return positions, nil
+}
+
+// UUID index of the object at index, a map holding it, or -1.
+func (t *tableDecoder) uuidIndex(index int) int {
+	o, err := t.object(index)
+	if err != nil || len(o.mapping) == 0 {
+		return -1
+	}
+	return int(o.mapping[0].value.number)
+}
+
+func (t *tableDecoder) object(index int) (object, error) {
+	if index < 0 || index >= len(t.objects) {
+		return object{}, errors.Errorf("no object %d", index)
+	}
+	return t.objects[index], nil
+}
+
+func decodeObject(data []byte) (object, error) {
+	o := object{mapType: -1}
+	err := walk(data, func(num protowire.Number, f field) error {
+		switch num {
+		case fieldEntryNote:
+			o.note = f.bytes
+		case fieldEntryDictionary:
+			var err error
+			o.dictionary, err = decodeDictionary(f.bytes)
+			return err
+		case fieldEntryCustomMap:
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				switch num {
+				case fieldMapType:
+					o.mapType = int(f.int32())
+				case fieldMapEntry:
+					key, value, err := decodeElement(f.bytes)
+					if err != nil {
+						return err
+					}
+					o.mapping = append(o.mapping, element{key: int(key.number), value: value})
+				}
+				return nil
+			})
+		case fieldEntryOrderedSet:
+			return walk(f.bytes, func(num protowire.Number, f field) error {
+				if num != fieldOrdering {
+					return nil
+				}
+				return walk(f.bytes, func(num protowire.Number, f field) error {
+					switch num {
+					case fieldOrderingArray:
+						return walk(f.bytes, func(num protowire.Number, f field) error {
+							if num != fieldArrayAttachment {
+								return nil
+							}
+							return walk(f.bytes, func(num protowire.Number, f field) error {
+								if num == fieldAttachmentUUID {
+									o.uuids = append(o.uuids, f.bytes)
+								}
+								return nil
+							})
+						})
+					case fieldOrderingContents:
+						var err error
+						o.contents, err = decodeDictionary(f.bytes)
+						return err
+					}
+					return nil
+				})
+			})
+		}
+		return nil
+	})
+	return o, err
+}
+
+// Elements of a dictionary, whose keys are object indexes.
+func decodeDictionary(data []byte) ([]element, error) {
+	var elements []element
+	err := walk(data, func(num protowire.Number, f field) error {
+		if num != fieldDictionaryElement {
+			return nil
+		}
+		key, value, err := decodeElement(f.bytes)
+		if err != nil {
+			return err
+		}
+		elements = append(elements, element{key: key.index, value: value})
+		return nil
+	})
+	return elements, err
+}
+
+// Key and value of a dictionary element or a map entry. Keys of maps are integers rather than objects.
+func decodeElement(data []byte) (objectID, objectID, error) {
+	key, value := objectID{index: -1}, objectID{index: -1}
+	err := walk(data, func(num protowire.Number, f field) error {
+		var err error
+		switch {
+		case num == fieldElementKey && f.typ == protowire.VarintType:
+			key.number = f.varint
+		case num == fieldElementKey:
+			key, err = decodeObjectID(f.bytes)
+		case num == fieldElementValue:
+			value, err = decodeObjectID(f.bytes)
+		}
+		return err
+	})
+	return key, value, err
+}
+
+func decodeObjectID(data []byte) (objectID, error) {
+	id := objectID{index: -1}
+	err := walk(data, func(num protowire.Number, f field) error {
+		switch num {
+		case fieldUnsignedIntegerValue:
+			id.number = f.varint
+		case fieldObjectIndex:
+			id.index = int(f.int32())
+		}
+		return nil
+	})
+	return id, err
+}
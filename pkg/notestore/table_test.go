@@ -0,0 +1,40 @@
+package notestore
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestDecodeTable(t *testing.T) {
+	table, err := DecodeTable(readFixture(t, "table.pb.gz"))
+	assert.NilError(t, err)
+	body := func(text string) Document {
+		return Document{Text: text, Runs: []Run{{Text: text, Paragraph: Paragraph{Style: StyleBody}}}}
+	}
+	assert.Check(t, is.DeepEqual(table.Cells, [][]Document{
+		{body("Ingredient"), body("Quantity")},
+		{body("Flour"), {Text: "200 g", Runs: []Run{{Text: "200 g", Paragraph: Paragraph{Style: StyleBody}, Bold: true}}}},
+	}))
+
+	_, err = DecodeTable(readFixture(t, "simple.pb.gz"))
+	assert.Check(t, is.ErrorContains(err, "no table object"))
+}
+
+func TestStoreTable(t *testing.T) {
+	s := open(t, "modern.sqlite")
+	table, err := s.Table(32)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(table.Cells, 2))
+	assert.Check(t, is.Equal(table.Cells[1][0].Text, "Flour"))
+
+	table, err = s.Table(30)
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(table))
+
+	legacy := open(t, "legacy.sqlite")
+	table, err = legacy.Table(11)
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(table))
+}
@@ -0,0 +1,291 @@
+//go:build ignore
+
+// Generate the note content fixtures, gzip-compressed protobuf as in the ZDATA column of NoteStore.sqlite, with:
+//
+//	go run testdata/generate.go
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type run struct {
+	text      string
+	style     int // -1 for none
+	indent    int
+	checked   int // -1 for no checklist
+	quote     bool
+	font      string
+	size      float32
+	weight    int
+	underline bool
+	strike    bool
+	super     int
+	link      string
+	color     []float32
+	id, uti   string
+}
+
+func plain(text string) run {
+	return run{text: text, style: -1, checked: -1}
+}
+
+func styled(text string, style int) run {
+	return run{text: text, style: style, checked: -1}
+}
+
+var fixtures = map[string][]run{
+	"simple.pb.gz": {
+		styled("Shopping list\n", 0),
+		plain("Milk, eggs\n"),
+	},
+	"formatted.pb.gz": {
+		styled("Chocolate cake\n", 0),
+		styled("Ingredients\n", 1),
+		{text: "Dark", style: -1, checked: -1, weight: 1},
+		plain(" chocolate, "),
+		{text: "butter", style: -1, checked: -1, weight: 2},
+		plain(", "),
+		{text: "sugar", style: -1, checked: -1, weight: 3},
+		plain(", "),
+		{text: "eggs", style: -1, checked: -1, underline: true},
+		plain(", "),
+		{text: "flour", style: -1, checked: -1, strike: true},
+		plain(" 🥚\n"),
+		styled("Steps\n", 2),
+		styled("Melt\n", 102),
+		{text: "Gently\n", style: 102, indent: 1, checked: -1},
+		styled("Bake\n", 102),
+		{text: "Buy cocoa\n", style: 103, checked: 1},
+		{text: "Buy cream\n", style: 103, checked: 0},
+		styled("Dots\n", 100),
+		styled("Dashes\n", 101),
+		styled("oven.temp = 180\n", 4),
+		{text: "Let it cool.\n", style: -1, checked: -1, quote: true},
+		{text: "Recipe", style: -1, checked: -1, link: "https://example.com/cake"},
+		plain(" from "),
+		{text: "Grandma", style: -1, checked: -1, font: "Noteworthy-Bold", size: 18, color: []float32{1, 0, 0, 1}},
+		plain(" H"),
+		{text: "2", style: -1, checked: -1, super: -1},
+		plain("O\n"),
+		{text: "\ufffc", style: -1, checked: -1, id: "ATTACHMENT-PHOTO", uti: "public.jpeg"},
+		plain("\n"),
+		{text: "\ufffc", style: -1, checked: -1, id: "ATTACHMENT-LINK", uti: "public.url"},
+		plain("\n"),
+		{text: "\ufffc", style: -1, checked: -1, id: "ATTACHMENT-TABLE", uti: "com.apple.notes.table"},
+		plain("\n"),
+	},
+}
+
+func main() {
+	for name, runs := range fixtures {
+		var document []byte
+		document = protowire.AppendTag(document, 2, protowire.VarintType)
+		document = protowire.AppendVarint(document, 0)
+		document = protowire.AppendTag(document, 3, protowire.BytesType)
+		document = protowire.AppendBytes(document, encodeNote(runs))
+		var store []byte
+		store = protowire.AppendTag(store, 2, protowire.BytesType)
+		store = protowire.AppendBytes(store, document)
+		write(name, store)
+	}
+	write("table.pb.gz", encodeTable())
+}
+
+func write(name string, data []byte) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("testdata", name), buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func encodeNote(runs []run) []byte {
+	var text string
+	var note []byte
+	for _, r := range runs {
+		text += r.text
+	}
+	note = protowire.AppendTag(note, 2, protowire.BytesType)
+	note = protowire.AppendString(note, text)
+	for _, r := range runs {
+		note = protowire.AppendTag(note, 5, protowire.BytesType)
+		note = protowire.AppendBytes(note, encodeRun(r))
+	}
+	return note
+}
+
+// Mergeable data of a table of 2 rows and 2 columns, with its UUIDs listed in another order than the rows.
+func encodeTable() []byte {
+	message := func(b []byte, num protowire.Number, m []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, m)
+	}
+	varint := func(b []byte, num protowire.Number, v uint64) []byte {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	}
+	ref := func(index uint64) []byte { return varint(nil, 6, index) }
+	number := func(n uint64) []byte { return varint(nil, 2, n) }
+	dictionary := func(pairs ...[2]uint64) []byte {
+		var d []byte
+		for _, p := range pairs {
+			d = message(d, 1, message(message(nil, 1, ref(p[0])), 2, ref(p[1])))
+		}
+		return d
+	}
+	customMap := func(typ uint64, entries ...[]byte) []byte {
+		m := varint(nil, 1, typ)
+		for _, e := range entries {
+			m = message(m, 3, e)
+		}
+		return message(nil, 13, m)
+	}
+	mapEntry := func(key uint64, value []byte) []byte {
+		return message(varint(nil, 1, key), 2, value)
+	}
+	orderedSet := func(uuids [][]byte, contents []byte) []byte {
+		var array []byte
+		array = message(array, 1, encodeNote(nil))
+		for i, u := range uuids {
+			array = message(array, 2, message(varint(nil, 1, uint64(i)), 2, u))
+		}
+		ordering := message(message(nil, 1, array), 2, contents)
+		return message(nil, 16, message(nil, 1, ordering))
+	}
+	uuid := func(c byte) []byte { return bytes.Repeat([]byte{c}, 16) }
+	row0, row1, col0, col1 := uuid('a'), uuid('b'), uuid('c'), uuid('d')
+	cell := func(runs ...run) []byte { return message(nil, 10, encodeNote(runs)) }
+
+	keys := []string{"crRows", "crColumns", "cellColumns", "UUIDIndex"}
+	types := []string{"com.apple.notes.ICTable", "com.apple.CRDT.NSUUID"}
+	uuids := [][]byte{row1, row0, col0, col1}
+	objects := [][]byte{
+		// 0: the table
+		customMap(0, mapEntry(0, ref(1)), mapEntry(1, ref(2)), mapEntry(2, ref(3))),
+		// 1, 2: rows and columns
+		orderedSet([][]byte{row0, row1}, dictionary([2]uint64{4, 4}, [2]uint64{5, 5})),
+		orderedSet([][]byte{col0, col1}, dictionary([2]uint64{6, 6}, [2]uint64{7, 7})),
+		// 3: cells, by column then row
+		message(nil, 6, dictionary([2]uint64{6, 8}, [2]uint64{7, 9})),
+		// 4 to 7: UUIDs of the rows and columns
+		customMap(1, mapEntry(3, number(1))),
+		customMap(1, mapEntry(3, number(0))),
+		customMap(1, mapEntry(3, number(2))),
+		customMap(1, mapEntry(3, number(3))),
+		// 8, 9: rows of each column
+		message(nil, 6, dictionary([2]uint64{4, 10}, [2]uint64{5, 11})),
+		message(nil, 6, dictionary([2]uint64{4, 12}, [2]uint64{5, 13})),
+		// 10 to 13: cells
+		cell(plain("Ingredient")),
+		cell(plain("Flour")),
+		cell(plain("Quantity")),
+		cell(run{text: "200 g", style: -1, checked: -1, weight: 1}),
+	}
+
+	var data []byte
+	for _, o := range objects {
+		data = message(data, 3, o)
+	}
+	for _, k := range keys {
+		data = message(data, 4, []byte(k))
+	}
+	for _, t := range types {
+		data = message(data, 5, []byte(t))
+	}
+	for _, u := range uuids {
+		data = message(data, 6, u)
+	}
+	object := message(varint(nil, 2, 0), 3, data)
+	return message(nil, 2, object)
+}
+
+func encodeRun(r run) []byte {
+	var b []byte
+	varint := func(b []byte, num protowire.Number, v int) []byte {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(int64(v)))
+	}
+	message := func(b []byte, num protowire.Number, m []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, m)
+	}
+	str := func(b []byte, num protowire.Number, s string) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendString(b, s)
+	}
+	float := func(b []byte, num protowire.Number, f float32) []byte {
+		b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+		return protowire.AppendFixed32(b, math.Float32bits(f))
+	}
+
+	b = varint(b, 1, len(utf16.Encode([]rune(r.text))))
+	if r.style >= 0 || r.indent > 0 || r.checked >= 0 || r.quote {
+		var p []byte
+		if r.style >= 0 {
+			p = varint(p, 1, r.style)
+		}
+		if r.indent > 0 {
+			p = varint(p, 4, r.indent)
+		}
+		if r.checked >= 0 {
+			var c []byte
+			c = message(c, 1, []byte("0123456789abcdef"))
+			c = varint(c, 2, r.checked)
+			p = message(p, 5, c)
+		}
+		if r.quote {
+			p = varint(p, 8, 1)
+		}
+		b = message(b, 2, p)
+	}
+	if r.font != "" {
+		var f []byte
+		f = str(f, 1, r.font)
+		f = float(f, 2, r.size)
+		b = message(b, 3, f)
+	}
+	if r.weight > 0 {
+		b = varint(b, 5, r.weight)
+	}
+	if r.underline {
+		b = varint(b, 6, 1)
+	}
+	if r.strike {
+		b = varint(b, 7, 1)
+	}
+	if r.super != 0 {
+		b = varint(b, 8, r.super)
+	}
+	if r.link != "" {
+		b = str(b, 9, r.link)
+	}
+	if r.color != nil {
+		var c []byte
+		for i, v := range r.color {
+			c = float(c, protowire.Number(i+1), v)
+		}
+		b = message(b, 10, c)
+	}
+	if r.id != "" {
+		var a []byte
+		a = str(a, 1, r.id)
+		a = str(a, 2, r.uti)
+		b = message(b, 12, a)
+	}
+	return b
+}
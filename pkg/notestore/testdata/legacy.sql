@@ -0,0 +1,43 @@
+-- Subset of the schema of NoteStore.sqlite on macOS 10.12, with made-up content. Rebuild legacy.sqlite with:
+--   rm -f legacy.sqlite && sqlite3 legacy.sqlite < legacy.sql
+CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER PRIMARY KEY, Z_NAME VARCHAR, Z_SUPER INTEGER, Z_MAX INTEGER);
+INSERT INTO Z_PRIMARYKEY VALUES
+  (3, 'ICCloudSyncingObject', 0, 12),
+  (4, 'ICAccount', 3, 1),
+  (5, 'ICAttachment', 3, 11),
+  (7, 'ICFolder', 3, 3),
+  (8, 'ICMedia', 3, 12),
+  (9, 'ICNote', 3, 6);
+
+CREATE TABLE ZICCLOUDSYNCINGOBJECT (
+  Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER, Z_OPT INTEGER,
+  ZIDENTIFIER VARCHAR, ZMARKEDFORDELETION INTEGER,
+  ZNAME VARCHAR,
+  ZTITLE2 VARCHAR, ZPARENT INTEGER, ZACCOUNT INTEGER, ZFOLDERTYPE INTEGER,
+  ZTITLE1 VARCHAR, ZSNIPPET VARCHAR, ZFOLDER INTEGER, ZNOTEDATA INTEGER,
+  ZCREATIONDATE1 TIMESTAMP, ZMODIFICATIONDATE1 TIMESTAMP, ZISPASSWORDPROTECTED INTEGER,
+  ZNOTE INTEGER, ZTYPEUTI VARCHAR, ZMEDIA INTEGER, ZFILENAME VARCHAR
+);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZNAME) VALUES
+  (1, 4, 'E5F6A7B8-ICLOUD', 'iCloud');
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZTITLE2, ZPARENT, ZACCOUNT, ZFOLDERTYPE) VALUES
+  (2, 7, 'FOLDER-NOTES', 'Notes', NULL, 1, 0),
+  (3, 7, 'FOLDER-WORK', 'Work', 2, 1, 0);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZTITLE1, ZSNIPPET, ZFOLDER, ZNOTEDATA,
+    ZCREATIONDATE1, ZMODIFICATIONDATE1, ZISPASSWORDPROTECTED) VALUES
+  (5, 9, 'NOTE-MEETING', 'Meeting', 'Agenda', 3, 1, 513065228.0, 558270733.0, 0),
+  (6, 9, 'NOTE-SCAN', 'Scan', NULL, 2, 2, 513065228.0, 513065228.0, NULL);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZNOTE, ZTYPEUTI, ZMEDIA) VALUES
+  (11, 5, 'ATTACHMENT-SCAN', 6, 'com.adobe.pdf', 12);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZFILENAME) VALUES
+  (12, 8, 'MEDIA-SCAN', 'scan.pdf');
+
+CREATE TABLE ZICNOTEDATA (Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER, Z_OPT INTEGER, ZNOTE INTEGER, ZDATA BLOB);
+INSERT INTO ZICNOTEDATA VALUES
+  (1, 6, 1, 5, NULL),
+  (2, 6, 1, 6, NULL);
@@ -0,0 +1,61 @@
+-- Subset of the schema of NoteStore.sqlite on recent macOS, with made-up content. Rebuild modern.sqlite with:
+--   rm -f modern.sqlite && sqlite3 modern.sqlite < modern.sql
+CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER PRIMARY KEY, Z_NAME VARCHAR, Z_SUPER INTEGER, Z_MAX INTEGER);
+INSERT INTO Z_PRIMARYKEY VALUES
+  (4, 'ICCloudSyncingObject', 0, 40),
+  (5, 'ICAttachment', 4, 32),
+  (11, 'ICMedia', 4, 40),
+  (12, 'ICNote', 4, 24),
+  (14, 'ICAccount', 4, 2),
+  (15, 'ICFolder', 4, 15);
+
+CREATE TABLE ZICCLOUDSYNCINGOBJECT (
+  Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER, Z_OPT INTEGER,
+  ZIDENTIFIER VARCHAR, ZMARKEDFORDELETION INTEGER,
+  ZNAME VARCHAR, ZACCOUNTTYPE INTEGER,
+  ZTITLE2 VARCHAR, ZPARENT INTEGER, ZOWNER INTEGER, ZFOLDERTYPE INTEGER,
+  ZTITLE1 VARCHAR, ZSNIPPET VARCHAR, ZFOLDER INTEGER, ZNOTEDATA INTEGER, ZACCOUNT4 INTEGER,
+  ZCREATIONDATE1 TIMESTAMP, ZCREATIONDATE3 TIMESTAMP, ZMODIFICATIONDATE1 TIMESTAMP,
+  ZISPINNED INTEGER, ZISPASSWORDPROTECTED INTEGER,
+  ZNOTE INTEGER, ZTYPEUTI VARCHAR, ZMEDIA INTEGER, ZURLSTRING VARCHAR, ZMERGEABLEDATA1 BLOB, ZFILENAME VARCHAR
+);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZNAME, ZACCOUNTTYPE) VALUES
+  (1, 14, 'A1B2C3D4-ICLOUD', 'iCloud', 1),
+  (2, 14, 'LocalAccount', 'On My Mac', 3);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZTITLE2, ZPARENT, ZOWNER, ZFOLDERTYPE, ZMARKEDFORDELETION) VALUES
+  (10, 15, 'FOLDER-NOTES', 'Notes', NULL, 1, 0, 0),
+  (11, 15, 'FOLDER-RECIPES', 'Recipes', NULL, 1, 0, 0),
+  (12, 15, 'FOLDER-DESSERTS', 'Desserts', 11, 1, 0, 0),
+  (13, 15, 'FOLDER-TRASH', 'Recently Deleted', NULL, 1, 1, 0),
+  (14, 15, 'FOLDER-LOCAL', 'Notes', NULL, 2, 0, 0),
+  (15, 15, 'FOLDER-GONE', 'Old', NULL, 1, 0, 1);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZTITLE1, ZSNIPPET, ZFOLDER, ZNOTEDATA, ZACCOUNT4,
+    ZCREATIONDATE1, ZCREATIONDATE3, ZMODIFICATIONDATE1, ZISPINNED, ZISPASSWORDPROTECTED, ZMARKEDFORDELETION) VALUES
+  (20, 12, 'NOTE-SHOPPING', 'Shopping list', 'Milk, eggs', 10, 1, 1, NULL, 662810400.0, 699625800.0, 1, 0, 0),
+  (21, 12, 'NOTE-CAKE', 'Chocolate cake', '200 g of chocolate', 12, 2, 1, NULL, 644745600.0, 705091530.0, 0, 0, 0),
+  (22, 12, 'NOTE-SECRET', 'Secret', NULL, 14, 3, 2, NULL, 602414100.0, 602503200.0, 0, 1, 0),
+  (23, 12, 'NOTE-TRASHED', 'Trashed', 'Gone soon', 13, 4, 1, NULL, 602414100.0, 602503200.0, 0, 0, 0),
+  (24, 12, 'NOTE-PURGED', 'Purged', NULL, 10, NULL, 1, NULL, 602414100.0, 602503200.0, 0, 0, 1);
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZNOTE, ZTYPEUTI, ZMEDIA, ZURLSTRING) VALUES
+  (30, 5, 'ATTACHMENT-PHOTO', 21, 'public.jpeg', 40, NULL),
+  (31, 5, 'ATTACHMENT-LINK', 21, 'public.url', NULL, 'https://example.com/cocoa');
+
+-- testdata/table.pb.gz
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZNOTE, ZTYPEUTI, ZMERGEABLEDATA1) VALUES
+  (32, 5, 'ATTACHMENT-TABLE', 21, 'com.apple.notes.table', X'1f8b08000000000000ff6c91cf6af24014c5936b12afa37edf70b12d5cba28ae4a1661c82b28a529b4d0541f20c6419438911869bbedbb74d3a72cda1a49ec6f75cf19e670ffd097232dfe74f87ac568b1871681b2d9439b40017b0804aac58f1ff683b8174a005934408b64d2800668939c35a0814001ca2150cea1720994fb475cdae0276edee037ce2350dea16a13a8365f86271f2bbfc3bd95c0fd2c2d0269d79455535053ad2a6fdfb6a8daeed6fc5ee5f799624922328b42cf97da943ea0e07edc25f72ecb77850fe8b28cff113eef12532ecb771f10f97fdc273754ea66e13be8deda432f2de2fc753beca4c528cf766bb31d76539d6547d1994ea37164e6facdbf4af375906c36990e4c5eea6d108d26c92cd3fec5e961148f27c1d3cbfe53787697f0ec7ea13caefe4878b6feef0100a333438d2d020000');
+
+INSERT INTO ZICCLOUDSYNCINGOBJECT (Z_PK, Z_ENT, ZIDENTIFIER, ZFILENAME) VALUES
+  (40, 11, 'MEDIA-PHOTO', 'cake.jpeg');
+
+CREATE TABLE ZICNOTEDATA (Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER, Z_OPT INTEGER, ZNOTE INTEGER, ZDATA BLOB);
+INSERT INTO ZICNOTEDATA VALUES
+  -- testdata/simple.pb.gz
+  (1, 9, 1, 20, X'1f8b08000000000000ff002d00d2ff122b10001a27121953686f7070696e67206c6973740a4d696c6b2c20656767730a2a06080e120208002a02080b0300759dd3bb2d000000'),
+  -- testdata/formatted.pb.gz
+  (2, 9, 1, 21, X'1f8b08000000000000ff7c91cf6e133d14c567fc4d2657fe1a25b21a5455541821a4602593b4fc0b4888266dd514d20495bc80c7b99984ce8c47331e203b1e81351b56bc05120b1e08166c5881264d2bd860c9d6f1bdc73febd8eca753b3b67f38ecab7d30d74a87d22057f21ce9491ca4385d606c327a28d373ae2efb4deee7c660dae4591ec8b4c93108b2269f853a4ff9f78f9f3ed09706938c9e6268e831c6265cd27e81ece74baeb4d2f242a528237aa857fc6c8e19d5af31f60c46097fc277bb1d3a44c317862bad438f9ea15a24c867a98ef8712ae36924f9606f4cbf7df97c35850b5546c0122e6c3002b670c069d882c08670c06d10418008074a8dffd6cae9d86b55ea16ca112eb8aca8b85062046602a0cc1c9871fbaa721d28db82406cd25a6777efeebdfb0f1e761f495f4d7156b3ffd9b5d68ca970a1cc08a070a1c62e6ead30b25f9c769f6dcd8d49b2c7ed36be955112a2a774d42ebe4410704507cadbd76875a40dbed1a9992f5b7d1d4eeb96f5be77b659b1ac774feb9665593bc572bbd8aef255c0deff75398aa044dc02dbbf416bbdc9a47730383d1a4d5a2f06e3c998fd9fe47eb850deab040351bce14db0fd1d5afdc3383c193d6774edcbd370656b81eddff98b37e9f58747acae74e4c9a408126b839967a41fa22060ff1e00304bf9407c020000'),
+  (3, 9, 1, 22, NULL),
+  (4, 9, 1, 23, NULL);
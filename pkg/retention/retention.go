@@ -0,0 +1,113 @@
+// Package retention selects the snapshots to keep with a grandfather-father-son policy.
+package retention
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Forever is a retention period which never ends.
+const Forever = time.Duration(math.MaxInt64)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+	year = 365 * day
+)
+
+// Policy keeps every snapshot younger than All, then the last snapshot of each day, week and month younger than
+// Daily, Weekly and Monthly. A zero period disables its tier.
+type Policy struct {
+	All     time.Duration
+	Daily   time.Duration
+	Weekly  time.Duration
+	Monthly time.Duration
+}
+
+// Default policy: everything for 7 days, dailies for 30 days, weeklies for a year and monthlies forever.
+func Default() Policy {
+	return Policy{
+		All:     7 * day,
+		Daily:   30 * day,
+		Weekly:  year,
+		Monthly: Forever,
+	}
+}
+
+// Select returns, for each snapshot time, whether the snapshot is kept at now. The most recent snapshot is always
+// kept.
+func (p Policy) Select(now time.Time, times []time.Time) []bool {
+	keep := make([]bool, len(times))
+	if len(times) == 0 {
+		return keep
+	}
+
+	// Visit from the newest, so that the first snapshot of a period is its last one.
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return times[order[a]].After(times[order[b]]) })
+	keep[order[0]] = true
+
+	tiers := []struct {
+		period time.Duration
+		bucket func(time.Time) string
+	}{
+		{p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.Weekly, func(t time.Time) string { y, w := t.ISOWeek(); return fmt.Sprintf("%d-W%02d", y, w) }},
+		{p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, tier := range tiers {
+		seen := map[string]bool{}
+		for _, i := range order {
+			if !within(now, times[i], tier.period) {
+				continue
+			}
+			if b := tier.bucket(times[i].Local()); !seen[b] {
+				seen[b] = true
+				keep[i] = true
+			}
+		}
+	}
+	for i, t := range times {
+		if within(now, t, p.All) {
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+func within(now, t time.Time, period time.Duration) bool {
+	return period == Forever || now.Sub(t) < period
+}
+
+// ParseDuration parses a retention period: a Go duration, a number of days ("30d"), weeks ("4w") or years ("1y"),
+// or "forever".
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "forever" {
+		return Forever, nil
+	}
+	if s == "" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{'d': day, 'w': week, 'y': year}
+	if unit, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(strings.TrimSpace(s[:len(s)-1]))
+		if err != nil {
+			return 0, errors.Errorf("invalid retention period %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid retention period %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,62 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestSelect(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	var times []time.Time
+	// Two backups a day for two years.
+	for h := 0; h < 2*365*24; h += 12 {
+		times = append(times, now.Add(-time.Duration(h)*time.Hour))
+	}
+	keep := Default().Select(now, times)
+
+	kept := map[string]int{}
+	for i, k := range keep {
+		if !k {
+			continue
+		}
+		age := now.Sub(times[i])
+		switch {
+		case age < 7*day:
+			kept["all"]++
+		case age < 30*day:
+			kept["daily"]++
+		case age < year:
+			kept["weekly"]++
+		default:
+			kept["monthly"]++
+		}
+	}
+	assert.Check(t, is.Equal(kept["all"], 14))
+	assert.Check(t, is.Equal(kept["daily"], 23))
+	// Weeklies, plus the monthlies which are not the last backup of their week.
+	assert.Check(t, kept["weekly"] >= 47 && kept["weekly"] <= 47+12, kept["weekly"])
+	assert.Check(t, kept["monthly"] >= 12 && kept["monthly"] <= 13, kept["monthly"])
+
+	// The newest snapshot is kept whatever the policy.
+	keep = Policy{}.Select(now, []time.Time{now.Add(-48 * time.Hour), now.Add(-24 * time.Hour)})
+	assert.Check(t, is.DeepEqual(keep, []bool{false, true}))
+}
+
+func TestParseDuration(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"7d":      7 * day,
+		"4w":      4 * week,
+		"1y":      year,
+		"36h":     36 * time.Hour,
+		"forever": Forever,
+	} {
+		d, err := ParseDuration(s)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(d, expected), s)
+	}
+	_, err := ParseDuration("soon")
+	assert.Check(t, is.ErrorContains(err, "invalid retention period"))
+}
@@ -0,0 +1,124 @@
+// Package sqlite takes consistent snapshots of SQLite databases which may be written to, with a pure Go driver.
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+)
+
+// Suffixes of the files of a database.
+const (
+	Ext    = ".sqlite"
+	walExt = "-wal"
+	shmExt = "-shm"
+)
+
+// Time waited for a lock held by a writer, in milliseconds.
+const busyTimeout = 10000
+
+// IsDatabaseFile reports whether name is a database, or its write-ahead log or shared memory file.
+func IsDatabaseFile(name string) bool {
+	return strings.HasSuffix(name, Ext) || strings.HasSuffix(name, Ext+walExt) || strings.HasSuffix(name, Ext+shmExt)
+}
+
+func open(path string) (*sql.DB, error) {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=busy_timeout(" + strconv.Itoa(busyTimeout) + ")"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection, so that pragmas apply to every statement.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// OpenImmutable opens the database at path read-only, without creating any file next to it, e.g. a snapshot. It
+// must not be written to while open.
+func OpenImmutable(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro&immutable=1")
+}
+
+// QueryInt runs a query returning a single integer on the database at path, which must not be written to.
+func QueryInt(path, query string) (int64, error) {
+	db, err := OpenImmutable(path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var n int64
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		return 0, errors.Wrapf(err, "failed to query %s", path)
+	}
+	return n, nil
+}
+
+// Snapshot writes a consistent copy of the database src to dst as a single file, with VACUUM INTO. Writers of src
+// are not blocked, and the content of its write-ahead log is included. dst is replaced atomically.
+func Snapshot(src, dst string) error {
+	db, err := open(src)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".snapshot-*"+Ext)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	// VACUUM INTO requires a new file.
+	if err := os.Remove(tmp.Name()); err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := db.Exec("VACUUM INTO ?", tmp.Name()); err != nil {
+		return errors.Wrapf(err, "failed to snapshot %s", src)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	for _, ext := range []string{walExt, shmExt} {
+		if err := os.Remove(dst + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// IntegrityCheck runs PRAGMA integrity_check on the database at path, and returns the problems it reports. A sound
+// database has none.
+func IntegrityCheck(path string) ([]string, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check %s", path)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
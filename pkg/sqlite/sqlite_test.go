@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestSnapshotWhileWriting(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "NoteStore.sqlite")
+	db, err := open(src)
+	assert.NilError(t, err)
+	defer db.Close()
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		"CREATE TABLE note (id INTEGER PRIMARY KEY, body TEXT)",
+		"CREATE TABLE counter (n INTEGER)",
+		"INSERT INTO counter VALUES (0)",
+	} {
+		_, err := db.Exec(stmt)
+		assert.NilError(t, err)
+	}
+
+	// Each transaction adds a note and increments the counter: a consistent snapshot has as many notes as the counter.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			tx, err := db.Begin()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, err = tx.Exec("INSERT INTO note (body) VALUES (?)", "some note content")
+			if err == nil {
+				_, err = tx.Exec("UPDATE counter SET n = n + 1")
+			}
+			if err != nil {
+				tx.Rollback()
+				t.Error(err)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	dst := filepath.Join(dir, "backup", "NoteStore.sqlite")
+	for i := 0; i < 10; i++ {
+		assert.NilError(t, Snapshot(src, dst))
+		snapshot, err := sql.Open("sqlite", dst)
+		assert.NilError(t, err)
+		var notes, counter int
+		assert.NilError(t, snapshot.QueryRow("SELECT COUNT(*) FROM note").Scan(&notes))
+		assert.NilError(t, snapshot.QueryRow("SELECT n FROM counter").Scan(&counter))
+		var mode string
+		assert.NilError(t, snapshot.QueryRow("PRAGMA journal_mode").Scan(&mode))
+		assert.NilError(t, snapshot.Close())
+		assert.Check(t, is.Equal(notes, counter))
+		assert.Check(t, is.Equal(mode, "delete"))
+	}
+	cancel()
+	wg.Wait()
+	assert.Check(t, !IsDatabaseFile("note.txt"))
+	assert.Check(t, IsDatabaseFile("NoteStore.sqlite-wal"))
+}
+
+func TestQueryInt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "NoteStore.sqlite")
+	db, err := open(path)
+	assert.NilError(t, err)
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		"CREATE TABLE note (body TEXT)",
+		"INSERT INTO note VALUES ('a'), ('b')",
+	} {
+		_, err := db.Exec(stmt)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, db.Close())
+
+	n, err := QueryInt(path, "SELECT COUNT(*) FROM note")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, int64(2)))
+	_, err = QueryInt(path, "SELECT COUNT(*) FROM missing")
+	assert.Check(t, is.ErrorContains(err, "no such table"))
+	_, err = os.Stat(path + walExt)
+	assert.Check(t, os.IsNotExist(err))
+}
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+)
+
+// Write a consistent snapshot of every database of src to the same path in dst, and delete the other database files
+// of dst: databases removed from src, and write-ahead logs, which snapshots include.
+func snapshotDatabases(src, dst string) error {
+	databases := map[string]bool{}
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || !strings.HasSuffix(path, sqlite.Ext) {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		databases[rel] = true
+		err = sqlite.Snapshot(path, filepath.Join(dst, rel))
+		if err == nil {
+			return nil
+		}
+		// Better an inconsistent copy than none.
+		log.Printf("%s; copying its files as they are", err.Error())
+		for _, ext := range []string{"", "-wal", "-shm"} {
+			if _, err := os.Lstat(path + ext); os.IsNotExist(err) {
+				continue
+			}
+			if err := copyFile(path+ext, filepath.Join(dst, rel+ext)); err != nil {
+				return err
+			}
+			databases[rel+ext] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var dirs []string
+	err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dst {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() || !sqlite.IsDatabaseFile(path) {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil || databases[rel] {
+			return err
+		}
+		dirs = append(dirs, filepath.Dir(path))
+		return os.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+	return removeEmptyDirs(dst, dirs)
+}
+
+func isNotDatabaseFile(name string) bool {
+	return !sqlite.IsDatabaseFile(name)
+}
+
+// Run the SQLite integrity check on every database of dir, and return the problems found, prefixed with the path of
+// the database.
+func checkDatabases(dir string) ([]string, error) {
+	var problems []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || !strings.HasSuffix(path, sqlite.Ext) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		found, err := checkDatabase(path, existingFile(path+"-wal"), existingFile(path+"-shm"))
+		if err != nil {
+			return err
+		}
+		for _, p := range found {
+			problems = append(problems, fmt.Sprintf("%s: %s", filepath.ToSlash(rel), p))
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// Path of a file, or empty if there is none.
+func existingFile(path string) string {
+	if _, err := os.Lstat(path); err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Prefix of the branches of devices, when each device backs up to its own branch.
+const deviceBranchPrefix = "device/"
+
+// Device backing up to the repository.
+type Device struct {
+	ID         string
+	LastBackup time.Time
+}
+
+// DeviceBranch is the branch the device id backs up to.
+func DeviceBranch(id string) string {
+	return deviceBranchPrefix + id
+}
+
+// DefaultDeviceID derives a device id from the hostname, e.g. "florians-macbook-pro" for "Florian's MacBook Pro.local".
+func DefaultDeviceID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".local")
+	id := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		case r == '\'':
+			return -1
+		default:
+			return '-'
+		}
+	}, hostname)
+	return strings.Trim(id, "-."), nil
+}
+
+// Devices backing up to the repository, with the date of their last backup.
+func (m *Link) Devices() ([]Device, error) {
+	branches, err := m.repo.Branches(deviceBranchPrefix)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, 0, len(branches))
+	for _, b := range branches {
+		devices = append(devices, Device{
+			ID:         strings.TrimPrefix(b.Name, deviceBranchPrefix),
+			LastBackup: b.When,
+		})
+	}
+	return devices, nil
+}
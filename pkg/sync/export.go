@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/export"
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/notestore"
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+	"github.com/pkg/errors"
+)
+
+// Directory of the repository holding the notes exported by backups, next to the backup directory.
+const notesDirname = "notes"
+
+// ExportOptions select the notes to export, and where.
+type ExportOptions struct {
+	Format export.Format
+	// Target directory the notes are written to. It must be empty or not exist yet.
+	Target string
+	// Revision exported: a snapshot name, a tag or a commit. The current notes are exported if Revision and Time are
+	// not set.
+	Revision string
+	// Time exported: the last snapshot at that time.
+	Time time.Time
+}
+
+// Export the notes as readable files, and return their number.
+func (m *Link) Export(opts ExportOptions) (int, error) {
+	if err := checkTarget(opts.Target, m.srcDir); err != nil {
+		return 0, err
+	}
+	tmpDir, err := os.MkdirTemp("", "notesforever-export-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	container := m.srcDir
+	if opts.Revision == "" && opts.Time.IsZero() {
+		// Notes may be writing to its database.
+		if err := sqlite.Snapshot(filepath.Join(m.srcDir, notestore.Filename), filepath.Join(tmpDir, notestore.Filename)); err != nil {
+			return 0, err
+		}
+	} else {
+		if _, err := m.extract(RestoreOptions{Revision: opts.Revision, Time: opts.Time}, tmpDir); err != nil {
+			return 0, err
+		}
+		if m.lfs != nil {
+			if err := m.lfs.Smudge(context.Background(), tmpDir); err != nil {
+				return 0, errors.Wrap(err, "failed to restore LFS objects")
+			}
+		}
+		container = tmpDir
+	}
+	return export.Export(filepath.Join(tmpDir, notestore.Filename), container, opts.Target, opts.Format)
+}
+
+// Write the notes of the backup to the notes directory, replacing the previous export. The database is read from the
+// backup, and attachments from the notes directory, as files of the backup may be LFS pointers.
+func (m *Link) exportNotes() error {
+	// Export next to the worktree, so that the previous export is kept if this one fails.
+	tmpDir, err := os.MkdirTemp(filepath.Join(m.repo.Dir(), ".git"), "export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, notesDirname)
+	n, err := export.Export(filepath.Join(m.dstDir(), notestore.Filename), m.srcDir, dir, m.export)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, git.DirPerm); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(m.notesDir()); err != nil {
+		return err
+	}
+	if err := os.Rename(dir, m.notesDir()); err != nil {
+		return err
+	}
+	log.Printf("%d note(s) exported", n)
+	return nil
+}
+
+func (m *Link) notesDir() string {
+	return filepath.Join(m.repo.Dir(), notesDirname)
+}
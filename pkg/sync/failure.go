@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/pkg/errors"
+)
+
+// Log of the refused backups, in the Git directory so that it is neither committed nor cleaned.
+const failuresFilename = "notesforever-failures.log"
+
+// RefusedError is returned by Backup when it refuses to commit a snapshot. The last good backup is kept as HEAD.
+type RefusedError struct {
+	Reason string
+}
+
+func (e *RefusedError) Error() string {
+	return "backup refused: " + e.Reason
+}
+
+// Refuse the backup started at start: restore the worktree of the last good backup, and record the failure.
+func (m *Link) refuse(start time.Time, reason string) error {
+	if err := m.repo.Reset(); err != nil {
+		return errors.Wrap(err, "failed to restore last backup")
+	}
+	if err := recordFailure(m.repo, start, reason); err != nil {
+		return errors.Wrap(err, "failed to record refused backup")
+	}
+	return &RefusedError{Reason: reason}
+}
+
+func failuresPath(repo *git.Repo) string {
+	return filepath.Join(repo.Dir(), ".git", failuresFilename)
+}
+
+func recordFailure(repo *git.Repo, when time.Time, reason string) error {
+	f, err := os.OpenFile(failuresPath(repo), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s\t%s\n", when.Format(time.RFC3339), strings.ReplaceAll(reason, "\n", " ")); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LastFailure returns the time and reason of the last refused backup of repo, or a zero time if there is none.
+func LastFailure(repo *git.Repo) (time.Time, string, error) {
+	f, err := os.Open(failuresPath(repo))
+	if os.IsNotExist(err) {
+		return time.Time{}, "", nil
+	}
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	defer f.Close()
+	var last string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			last = scanner.Text()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, "", err
+	}
+	if last == "" {
+		return time.Time{}, "", nil
+	}
+	date, reason, _ := strings.Cut(last, "\t")
+	when, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, "", errors.Wrap(err, "invalid failure record")
+	}
+	return when, reason, nil
+}
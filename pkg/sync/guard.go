@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/manifest"
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+	"github.com/pkg/errors"
+)
+
+// Guard sets the largest drops from the previous backup, as fractions of its values, above which a backup is
+// quarantined instead of committed. Zero disables a check.
+type Guard struct {
+	// Notes is the drop of the number of notes in the Notes database.
+	Notes float64
+	// Files is the drop of the number of files.
+	Files float64
+	// Bytes is the drop of the total size of files.
+	Bytes float64
+}
+
+// Database of Notes, at the root of its container, and query counting the notes which are not deleted.
+const (
+	noteStoreFilename = "NoteStore.sqlite"
+	countNotesQuery   = "SELECT COUNT(*) FROM ZICCLOUDSYNCINGOBJECT WHERE ZNOTEDATA IS NOT NULL AND IFNULL(ZMARKEDFORDELETION, 0) = 0"
+)
+
+// Prefix of the branches holding quarantined backups.
+const quarantineBranchPrefix = "quarantine/"
+
+// QuarantinedError is returned by Backup when too much was deleted since the previous backup. The backup is
+// committed to Branch instead, and the last good backup is kept as HEAD.
+type QuarantinedError struct {
+	Branch string
+	Reason string
+}
+
+func (e *QuarantinedError) Error() string {
+	return fmt.Sprintf("backup quarantined to branch %s: %s", e.Branch, e.Reason)
+}
+
+// Counts of a backup, recorded in its commit message to compare the next backup with.
+type backupStats struct {
+	// Notes is -1 when the Notes database cannot be read.
+	Notes int64
+	Files int64
+	Bytes int64
+}
+
+// Counts of the backup directory dir, whose manifest is mf.
+func statsOf(dir string, mf *manifest.Manifest) backupStats {
+	s := backupStats{Notes: -1}
+	for _, e := range mf.Entries {
+		if e.Type == manifest.File {
+			s.Files++
+			s.Bytes += e.Size
+		}
+	}
+	if n, err := sqlite.QueryInt(filepath.Join(dir, noteStoreFilename), countNotesQuery); err == nil {
+		s.Notes = n
+	}
+	return s
+}
+
+func (s backupStats) trailers() []git.Trailer {
+	trailers := []git.Trailer{
+		{Key: TrailerFiles, Value: strconv.FormatInt(s.Files, 10)},
+		{Key: TrailerBytes, Value: strconv.FormatInt(s.Bytes, 10)},
+	}
+	if s.Notes >= 0 {
+		trailers = append(trailers, git.Trailer{Key: TrailerNotes, Value: strconv.FormatInt(s.Notes, 10)})
+	}
+	return trailers
+}
+
+// Counts recorded in the trailers of a commit message, and whether there were any.
+func parseStats(trailers map[string]string) (backupStats, bool) {
+	s := backupStats{Notes: -1}
+	var err error
+	if s.Files, err = strconv.ParseInt(trailers[TrailerFiles], 10, 64); err != nil {
+		return s, false
+	}
+	if s.Bytes, err = strconv.ParseInt(trailers[TrailerBytes], 10, 64); err != nil {
+		return s, false
+	}
+	if n, err := strconv.ParseInt(trailers[TrailerNotes], 10, 64); err == nil {
+		s.Notes = n
+	}
+	return s, true
+}
+
+// Reasons to quarantine a backup with the counts next, after a backup with the counts prev.
+func (g Guard) check(prev, next backupStats) []string {
+	var reasons []string
+	drop := func(what string, limit float64, prev, next int64, format func(int64) string) {
+		if limit <= 0 || prev <= 0 || next >= prev {
+			return
+		}
+		if ratio := float64(prev-next) / float64(prev); ratio > limit {
+			reasons = append(reasons, fmt.Sprintf("%s dropped by %.0f%%, from %s to %s", what, ratio*100, format(prev), format(next)))
+		}
+	}
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+	if prev.Notes >= 0 && next.Notes >= 0 {
+		drop("notes", g.Notes, prev.Notes, next.Notes, count)
+	}
+	drop("files", g.Files, prev.Files, next.Files, count)
+	drop("size", g.Bytes, prev.Bytes, next.Bytes, FormatBytes)
+	return reasons
+}
+
+// Counts of the last backup recording any, following first parents past commits which do not, e.g. merges of the
+// remote history, and whether one was found.
+func (m *Link) lastStats() (backupStats, bool, error) {
+	history, err := m.repo.History()
+	if err != nil {
+		return backupStats{}, false, err
+	}
+	for _, rev := range history {
+		if s, ok := parseStats(git.ParseTrailers(rev.Message)); ok {
+			return s, true, nil
+		}
+	}
+	return backupStats{}, false, nil
+}
+
+// Commit the staged changes to a quarantine branch, keep the last good backup, and record the failure.
+func (m *Link) quarantine(start time.Time, message, reason string) error {
+	name := quarantineBranchPrefix + start.Format(snapshotLayout)
+	if m.deviceID != "" {
+		name = quarantineBranchPrefix + m.deviceID + "/" + start.Format(snapshotLayout)
+	}
+	branch := name
+	for i := 2; m.branchExists(branch); i++ {
+		branch = fmt.Sprintf("%s-%d", name, i)
+	}
+	if _, err := m.repo.CommitToBranch(branch, message); err != nil {
+		return errors.Wrap(err, "failed to commit quarantined backup")
+	}
+	if err := recordFailure(m.repo, start, fmt.Sprintf("quarantined to branch %s: %s", branch, reason)); err != nil {
+		return errors.Wrap(err, "failed to record quarantined backup")
+	}
+	return &QuarantinedError{Branch: branch, Reason: reason}
+}
+
+func (m *Link) branchExists(name string) bool {
+	_, err := m.repo.ResolveBranch(name)
+	return err == nil
+}
@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/floriankarydes/notesforever/pkg/lfs"
+	"github.com/floriankarydes/notesforever/pkg/manifest"
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+	"github.com/pkg/errors"
+)
+
+// Read the entries of the files of dir, without their size and hash, by path relative to dir.
+func statFiles(dir string) (map[string]manifest.Entry, error) {
+	entries := map[string]manifest.Entry{}
+	// Unlike Walk, WalkDir reads directories after visiting them, so their access time is not changed yet.
+	err := filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		e, err := manifest.Stat(path)
+		if err != nil {
+			return err
+		}
+		e.Path = filepath.ToSlash(rel)
+		entries[e.Path] = e
+		return nil
+	})
+	return entries, err
+}
+
+// Write the manifest of the backup directory next to it, with the entries of the notes files read before the backup.
+func (m *Link) writeManifest(entries map[string]manifest.Entry) (*manifest.Manifest, error) {
+	path := filepath.Join(m.repo.Dir(), manifest.Filename)
+	previous, err := manifest.Read(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to read previous manifest: %s; hashing all files", err.Error())
+		}
+		previous = &manifest.Manifest{}
+	}
+	built, err := buildManifest(entries, m.dstDir(), previous, m.checksum)
+	if err != nil {
+		return nil, err
+	}
+	return built, built.Write(path)
+}
+
+// Build the manifest of the files of dst, with the given entries of the same files in the notes directory. Contents
+// are those of dst, as databases are snapshots of the notes ones. Hashes of files with the same size and
+// modification time as in the previous manifest are reused, unless checksum is set.
+func buildManifest(entries map[string]manifest.Entry, dst string, previous *manifest.Manifest, checksum bool) (*manifest.Manifest, error) {
+	result := &manifest.Manifest{}
+	err := filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		e, ok := entries[filepath.ToSlash(rel)]
+		if !ok {
+			if e, err = manifest.Stat(path); err != nil {
+				return err
+			}
+			e.Path = filepath.ToSlash(rel)
+		}
+		prev, found := previous.Lookup(e.Path)
+		if e.Type == manifest.File {
+			if e.Size, e.SHA256, err = contentHash(path, info, e, prev, found && !checksum); err != nil {
+				return errors.Wrapf(err, "failed to hash %s", e.Path)
+			}
+		}
+		// Reading a file is not a change worth a commit.
+		if found && sameExceptAtime(e, prev) {
+			e.Atime = prev.Atime
+		}
+		result.Entries = append(result.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func sameExceptAtime(a, b manifest.Entry) bool {
+	if a.Path != b.Path || a.Type != b.Type || a.Size != b.Size || a.SHA256 != b.SHA256 || a.Mode != b.Mode ||
+		a.UID != b.UID || a.GID != b.GID || !a.Mtime.Equal(b.Mtime) || len(a.Xattrs) != len(b.Xattrs) {
+		return false
+	}
+	for name, value := range a.Xattrs {
+		if other, ok := b.Xattrs[name]; !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// Size and hash of the content of the file at path, whose entry is e, reusing the ones of its previous entry if the
+// file looks unchanged. LFS pointers have the size and hash of the content they point to.
+func contentHash(path string, info os.FileInfo, e, prev manifest.Entry, reuse bool) (int64, string, error) {
+	if reuse && prev.SHA256 != "" && prev.Size == info.Size() && prev.Mtime.Equal(e.Mtime) && !sqlite.IsDatabaseFile(path) {
+		return prev.Size, prev.SHA256, nil
+	}
+	if p, err := lfs.ReadPointerFile(path); err == nil {
+		return p.Size, p.Oid, nil
+	}
+	sum, err := fileHash(path)
+	return info.Size(), sum, err
+}
+
+// Manifest of the backup at the root of the worktree, or nil if there is none.
+func (m *Link) worktreeManifest() (*manifest.Manifest, error) {
+	mf, err := manifest.Read(filepath.Join(m.repo.Dir(), manifest.Filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return mf, err
+}
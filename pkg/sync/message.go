@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/export"
+	"github.com/floriankarydes/notesforever/pkg/git"
+)
+
+// Trailer keys recording backup metadata in commit messages.
+const (
+	TrailerHost     = "Backup-Host"
+	TrailerVersion  = "Backup-Version"
+	TrailerSource   = "Backup-Source"
+	TrailerDuration = "Backup-Duration"
+	TrailerDevice   = "Backup-Device"
+	TrailerNotes    = "Backup-Notes"
+	TrailerFiles    = "Backup-Files"
+	TrailerBytes    = "Backup-Bytes"
+)
+
+// Number of changed files listed in a commit message.
+const maxListedFiles = 20
+
+// Build the commit message of a backup from its staged changes.
+func (m *Link) commitMessage(changes []git.Change, duration time.Duration, stats backupStats) string {
+	notes := exportedNotes(changes, m.export)
+	changes = noteChanges(changes)
+	var b strings.Builder
+	b.WriteString(summarize(changes))
+	b.WriteString("\n\n")
+
+	if len(notes) > 0 {
+		b.WriteString("Notes:\n")
+		for i, c := range notes {
+			if i == maxListedFiles {
+				fmt.Fprintf(&b, "... and %d more\n", len(notes)-maxListedFiles)
+				break
+			}
+			dir, file := path.Split(strings.TrimPrefix(c.Path, notesDirname+"/"))
+			fmt.Fprintf(&b, "%s %s (%s)\n", actionSymbol(c.Action), strings.TrimSuffix(file, m.export.Ext()), strings.TrimSuffix(dir, "/"))
+		}
+		b.WriteString("\nFiles:\n")
+	}
+
+	for i, c := range changes {
+		if i == maxListedFiles {
+			fmt.Fprintf(&b, "... and %d more\n", len(changes)-maxListedFiles)
+			break
+		}
+		fmt.Fprintf(&b, "%s %s (%s)\n", actionSymbol(c.Action), c.Path, formatDelta(c.Delta))
+	}
+	b.WriteString("\n")
+
+	hostname, _ := os.Hostname()
+	trailers := []git.Trailer{
+		{Key: TrailerHost, Value: hostname},
+		{Key: TrailerVersion, Value: m.version},
+		{Key: TrailerSource, Value: m.srcDir},
+		{Key: TrailerDuration, Value: duration.Round(time.Millisecond).String()},
+	}
+	if m.deviceID != "" {
+		trailers = append(trailers, git.Trailer{Key: TrailerDevice, Value: m.deviceID})
+	}
+	trailers = append(trailers, stats.trailers()...)
+	b.WriteString(git.FormatTrailers(trailers))
+	return b.String()
+}
+
+// Changes of backed up files, leaving out the manifest and other bookkeeping files, unless nothing else changed.
+func noteChanges(changes []git.Change) []git.Change {
+	var notes []git.Change
+	for _, c := range changes {
+		if strings.HasPrefix(c.Path, backupDirname+"/") {
+			notes = append(notes, c)
+		}
+	}
+	if len(notes) == 0 {
+		return changes
+	}
+	return notes
+}
+
+// Changes of the files of notes exported in format, leaving out their attachments and index pages. Their name is the
+// title of the note, and their directory the account and folder of the note.
+func exportedNotes(changes []git.Change, format export.Format) []git.Change {
+	if format == "" {
+		return nil
+	}
+	var notes []git.Change
+	for _, c := range changes {
+		dir, file := path.Split(c.Path)
+		if !strings.HasPrefix(dir, notesDirname+"/") || path.Ext(file) != format.Ext() || file == export.IndexFilename ||
+			strings.HasSuffix(dir, export.AttachmentsSuffix+"/") {
+			continue
+		}
+		notes = append(notes, c)
+	}
+	return notes
+}
+
+// One-line summary of changes, e.g. "Backup: 2 added, 1 modified (+1.2 MB)".
+func summarize(changes []git.Change) string {
+	counts := map[git.Action]int{}
+	var delta int64
+	for _, c := range changes {
+		counts[c.Action]++
+		delta += c.Delta
+	}
+	var parts []string
+	for _, a := range []git.Action{git.Added, git.Modified, git.Deleted} {
+		if counts[a] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[a], a))
+		}
+	}
+	return fmt.Sprintf("Backup: %s (%s)", strings.Join(parts, ", "), formatDelta(delta))
+}
+
+func actionSymbol(a git.Action) string {
+	switch a {
+	case git.Added:
+		return "A"
+	case git.Deleted:
+		return "D"
+	default:
+		return "M"
+	}
+}
+
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + FormatBytes(-delta)
+	}
+	return "+" + FormatBytes(delta)
+}
+
+// FormatBytes formats a size with SI units, e.g. "1.2 MB".
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
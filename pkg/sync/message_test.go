@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/export"
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestCommitMessage(t *testing.T) {
+	m := &Link{srcDir: "/notes", version: "1.2.3"}
+	msg := m.commitMessage([]git.Change{
+		{Path: "backup/NoteStore.sqlite", Action: git.Modified, Size: 3000000, Delta: 1200000},
+		{Path: "backup/Media/a.jpg", Action: git.Added, Size: 2500, Delta: 2500},
+		{Path: "backup/Media/b.jpg", Action: git.Deleted, Delta: -500},
+	}, 1500*time.Millisecond, backupStats{Notes: 12, Files: 40, Bytes: 3000000})
+
+	subject, _, _ := strings.Cut(msg, "\n")
+	assert.Check(t, is.Equal(subject, "Backup: 1 added, 1 modified, 1 deleted (+1.2 MB)"))
+	assert.Check(t, is.Contains(msg, "A backup/Media/a.jpg (+2.5 kB)\n"))
+	assert.Check(t, is.Contains(msg, "D backup/Media/b.jpg (-500 B)\n"))
+
+	trailers := git.ParseTrailers(msg)
+	assert.Check(t, is.Equal(trailers[TrailerVersion], "1.2.3"))
+	assert.Check(t, is.Equal(trailers[TrailerSource], "/notes"))
+	assert.Check(t, is.Equal(trailers[TrailerDuration], "1.5s"))
+	stats, ok := parseStats(trailers)
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(stats, backupStats{Notes: 12, Files: 40, Bytes: 3000000}))
+}
+
+func TestCommitMessageNotes(t *testing.T) {
+	m := &Link{srcDir: "/notes", version: "1.2.3", export: export.HTML}
+	msg := m.commitMessage([]git.Change{
+		{Path: "backup/NoteStore.sqlite", Action: git.Modified, Size: 3000000, Delta: 1200000},
+		{Path: "notes/index.html", Action: git.Modified, Size: 100, Delta: 10},
+		{Path: "notes/iCloud/Recipes/Desserts/Chocolate cake.html", Action: git.Modified, Size: 100, Delta: 10},
+		{Path: "notes/iCloud/Recipes/Desserts/Chocolate cake attachments/cake.html", Action: git.Added, Size: 10, Delta: 10},
+		{Path: "notes/iCloud/Notes/Shopping list.html", Action: git.Deleted, Delta: -100},
+	}, time.Second, backupStats{})
+
+	subject, body, _ := strings.Cut(msg, "\n\n")
+	assert.Check(t, is.Equal(subject, "Backup: 1 modified (+1.2 MB)"))
+	assert.Check(t, strings.HasPrefix(body, "Notes:\n"+
+		"M Chocolate cake (iCloud/Recipes/Desserts)\n"+
+		"D Shopping list (iCloud/Notes)\n"+
+		"\nFiles:\n"+
+		"M backup/NoteStore.sqlite (+1.2 MB)\n"), body)
+}
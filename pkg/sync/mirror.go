@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/pkg/errors"
+)
+
+// Make dst a copy of src, only copying the files which changed and deleting the removed ones. Unchanged files are
+// left untouched. It returns the changes applied.
+func mirror(src, dst string, opts compareOptions) ([]git.Change, error) {
+	changes, err := diffDirs(src, dst, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dst, git.DirPerm); err != nil {
+		return nil, err
+	}
+
+	// Delete first, so that a file can replace a directory and the other way around.
+	var dirs []string
+	for _, c := range changes {
+		if c.Action == git.Deleted {
+			target := filepath.Join(dst, filepath.FromSlash(c.Path))
+			if err := os.Remove(target); err != nil {
+				return nil, errors.Wrapf(err, "failed to delete %s", c.Path)
+			}
+			dirs = append(dirs, filepath.Dir(target))
+		}
+	}
+	if err := removeEmptyDirs(dst, dirs); err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		if c.Action == git.Deleted {
+			continue
+		}
+		from := filepath.Join(src, filepath.FromSlash(c.Path))
+		to := filepath.Join(dst, filepath.FromSlash(c.Path))
+		if err := copyFile(from, to); err != nil {
+			return nil, errors.Wrapf(err, "failed to copy %s", c.Path)
+		}
+	}
+	return changes, nil
+}
+
+// Remove the empty directories among dirs and their parents, up to root excluded.
+func removeEmptyDirs(root string, dirs []string) error {
+	// Deepest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		for dir != root && len(dir) > len(root) {
+			entries, err := os.ReadDir(dir)
+			if os.IsNotExist(err) {
+				dir = filepath.Dir(dir)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				break
+			}
+			if err := os.Remove(dir); err != nil {
+				return err
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+	return nil
+}
+
+// Copy a file or a symlink, replacing dst. Regular files keep their mode and modification time.
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), git.DirPerm); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/lfs"
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+)
+
+// PlanBackup returns the changes a backup would make to the backup directory, without changing anything.
+func (m *Link) PlanBackup() ([]git.Change, error) {
+	changes, err := diffDirs(m.srcDir, m.dstDir(), compareOptions{checksum: true, skip: sqlite.IsDatabaseFile})
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare snapshots of the databases, as they would be committed.
+	tmpDir, err := os.MkdirTemp("", "notesforever-plan-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := snapshotDatabases(m.srcDir, tmpDir); err != nil {
+		return nil, err
+	}
+	dbChanges, err := diffDirs(tmpDir, m.dstDir(), compareOptions{checksum: true, skip: isNotDatabaseFile})
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, dbChanges...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// PlanRestore returns the changes a restore would make to the notes directory, or to the target, without changing
+// anything. Deleted files are the ones moved away with the saved notes directory.
+func (m *Link) PlanRestore(opts RestoreOptions) ([]git.Change, error) {
+	fromDir, _, cleanup, err := m.restoreSource(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	toDir := m.srcDir
+	if opts.Target != "" {
+		if err := checkTarget(opts.Target, m.srcDir); err != nil {
+			return nil, err
+		}
+		toDir = opts.Target
+	}
+	return diffDirs(fromDir, toDir, compareOptions{checksum: true})
+}
+
+// FormatPlan lists planned changes with their size, e.g. "M NoteStore.sqlite 3.0 MB (+1.2 MB)", after a summary.
+func FormatPlan(changes []git.Change) string {
+	if len(changes) == 0 {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimPrefix(summarize(changes), "Backup: ") + "\n")
+	for _, c := range changes {
+		fmt.Fprintf(&b, "%s %s %s (%s)\n", actionSymbol(c.Action), c.Path, FormatBytes(c.Size), formatDelta(c.Delta))
+	}
+	return b.String()
+}
+
+// Options of the comparison of directories.
+type compareOptions struct {
+	// Files with the same size and modification time are the same, unless checksum is true, which compares the
+	// content of files with the same size.
+	checksum bool
+	// Files skipped on both sides, by slash-separated relative path.
+	skip func(name string) bool
+}
+
+// Compare the files of src to the ones of dst, and return the changes turning dst into src, sorted by path. A
+// missing directory has no files.
+func diffDirs(src, dst string, opts compareOptions) ([]git.Change, error) {
+	srcFiles, err := listFiles(src, opts.skip)
+	if err != nil {
+		return nil, err
+	}
+	dstFiles, err := listFiles(dst, opts.skip)
+	if err != nil {
+		return nil, err
+	}
+	var changes []git.Change
+	for name, si := range srcFiles {
+		di, ok := dstFiles[name]
+		if !ok {
+			changes = append(changes, git.Change{Path: name, Action: git.Added, Size: si.Size(), Delta: si.Size()})
+			continue
+		}
+		same, err := sameFile(filepath.Join(src, name), filepath.Join(dst, name), si, di, opts.checksum)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, git.Change{Path: name, Action: git.Modified, Size: si.Size(), Delta: si.Size() - di.Size()})
+		}
+	}
+	for name, di := range dstFiles {
+		if _, ok := srcFiles[name]; !ok {
+			changes = append(changes, git.Change{Path: name, Action: git.Deleted, Delta: -di.Size()})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// Files and symlinks under dir, by slash-separated relative path, except the skipped ones.
+func listFiles(dir string, skip func(name string) bool) (map[string]os.FileInfo, error) {
+	files := map[string]os.FileInfo{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); skip == nil || !skip(name) {
+			files[name] = info
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Report whether two files have the same content. A file stored as an LFS pointer is compared to the pointed
+// content.
+func sameFile(a, b string, ai, bi os.FileInfo, checksum bool) (bool, error) {
+	if ai.Mode()&os.ModeSymlink != 0 || bi.Mode()&os.ModeSymlink != 0 {
+		if ai.Mode().Type() != bi.Mode().Type() {
+			return false, nil
+		}
+		at, err := os.Readlink(a)
+		if err != nil {
+			return false, err
+		}
+		bt, err := os.Readlink(b)
+		return at == bt, err
+	}
+	if ai.Size() != bi.Size() {
+		if p, err := lfs.ReadPointerFile(b); err == nil {
+			return matchesPointer(a, ai, bi, p, checksum)
+		}
+		if p, err := lfs.ReadPointerFile(a); err == nil {
+			return matchesPointer(b, bi, ai, p, checksum)
+		}
+		return false, nil
+	}
+	if !checksum {
+		return ai.ModTime().Equal(bi.ModTime()), nil
+	}
+	return sameContent(a, b)
+}
+
+// Report whether the file at path has the content of the pointer p, whose file info is pi.
+func matchesPointer(path string, info, pi os.FileInfo, p lfs.Pointer, checksum bool) (bool, error) {
+	if info.Size() != p.Size {
+		return false, nil
+	}
+	if !checksum {
+		return info.ModTime().Equal(pi.ModTime()), nil
+	}
+	sum, err := fileHash(path)
+	return sum == p.Oid, err
+}
+
+// Hex SHA-256 of the file at path.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/retention"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+)
+
+// Prefix of the safety tags created before history is rewritten.
+const pruneTagPrefix = "prune/"
+
+// PruneOptions configure Prune.
+type PruneOptions struct {
+	Policy retention.Policy
+	// DryRun only reports what would be pruned.
+	DryRun bool
+	// ForcePush overwrites the remote history with the pruned one. Otherwise, only a history never pushed is
+	// rewritten.
+	ForcePush bool
+}
+
+// PruneResult reports the outcome of Prune.
+type PruneResult struct {
+	Kept    int
+	Dropped int
+	// SafetyTag points at the history before pruning.
+	SafetyTag string
+	Pushed    bool
+}
+
+// Prune thins the backup history with the retention policy.
+func (m *Link) Prune(opts PruneOptions) (*PruneResult, error) {
+	history, err := m.repo.History()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	times := make([]time.Time, len(history))
+	for i, rev := range history {
+		times[i] = rev.When
+	}
+	// Pinned snapshots are always kept.
+	keep := map[plumbing.Hash]bool{}
+	pins, err := m.repo.Tags(pinTagPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range pins {
+		keep[t.Hash] = true
+	}
+	res := &PruneResult{}
+	for i, k := range opts.Policy.Select(now, times) {
+		if k || keep[history[i].Hash] {
+			keep[history[i].Hash] = true
+			res.Kept++
+		} else {
+			res.Dropped++
+		}
+	}
+	if res.Dropped == 0 {
+		return res, nil
+	}
+	// The next pull would merge the former history back, or reset to it.
+	if !opts.ForcePush {
+		if err := m.checkRemoteKept(history, keep); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	res.SafetyTag = pruneTagPrefix + now.Format("2006-01-02T15-04-05")
+	rewritten, err := m.repo.Rewrite(keep, res.SafetyTag)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to rewrite history")
+	}
+	if err := m.retag(history, rewritten); err != nil {
+		return nil, errors.Wrap(err, "failed to move snapshot tags to the pruned history")
+	}
+	if opts.ForcePush {
+		if _, err := m.repo.ForcePush(); err != nil {
+			return res, errors.Wrap(err, "failed to force-push pruned history")
+		}
+		res.Pushed = true
+	}
+	return res, nil
+}
+
+// Check that the primary remote has none of the commits of history which pruning removes or rewrites: the ones newer
+// than the oldest commit dropped.
+func (m *Link) checkRemoteKept(history []git.Revision, keep map[plumbing.Hash]bool) error {
+	remote, err := m.repo.RemoteHead(m.repo.Remotes()[0])
+	if err != nil {
+		return err
+	}
+	if remote.IsZero() {
+		return nil
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if !keep[history[i].Hash] {
+			break
+		}
+		if history[i].Hash == remote {
+			return nil
+		}
+	}
+	return ErrRemoteHistory
+}
+
+// ErrRemoteHistory is returned by Prune when the remote has the history to prune, and it may not be overwritten.
+var ErrRemoteHistory = errors.New("the remote has the history to prune, which the next pull would bring back; " +
+	"prune with --force-push to overwrite it")
+
+// Move the snapshot and pin tags of the history to the rewritten commits, and delete the ones of dropped commits.
+func (m *Link) retag(history []git.Revision, rewritten map[plumbing.Hash]plumbing.Hash) error {
+	inHistory := map[plumbing.Hash]bool{}
+	for _, rev := range history {
+		inHistory[rev.Hash] = true
+	}
+	for _, prefix := range []string{snapshotTagPrefix, pinTagPrefix} {
+		tags, err := m.repo.Tags(prefix)
+		if err != nil {
+			return err
+		}
+		for _, t := range tags {
+			if hash, ok := rewritten[t.Hash]; ok {
+				err = m.repo.SetTag(t.Name, hash)
+			} else if inHistory[t.Hash] {
+				err = m.repo.DeleteTag(t.Name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
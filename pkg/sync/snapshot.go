@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/manifest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+)
+
+// Tags naming snapshots, and pinning the snapshots that pruning never removes.
+const (
+	snapshotTagPrefix = "snapshot/"
+	pinTagPrefix      = "pin/"
+	// Layout of the date in snapshot names, e.g. "2026-10-16T00-00".
+	snapshotLayout = "2006-01-02T15-04"
+)
+
+// Snapshot is a backup commit named by a tag.
+type Snapshot struct {
+	// Name of the snapshot, e.g. "2026-10-16T00-00", prefixed by the device id with device branches.
+	Name    string
+	Hash    plumbing.Hash
+	When    time.Time
+	Device  string
+	Size    int64
+	Summary string
+	Pinned  bool
+}
+
+// SnapshotFilter selects snapshots by date. Zero times are unbounded.
+type SnapshotFilter struct {
+	Since time.Time
+	Until time.Time
+}
+
+// TagPrefixes are the tags pushed along with the backups of the device id, which is empty if devices share a branch.
+func TagPrefixes(deviceID string) []string {
+	var device string
+	if deviceID != "" {
+		device = deviceID + "/"
+	}
+	return []string{snapshotTagPrefix + device, pinTagPrefix + device}
+}
+
+// Tag the backup commit hash with a new snapshot name.
+func (m *Link) tagSnapshot(hash plumbing.Hash, when time.Time) (string, error) {
+	name := when.Format(snapshotLayout)
+	if m.deviceID != "" {
+		name = m.deviceID + "/" + name
+	}
+	tags, err := m.repo.Tags(snapshotTagPrefix + name)
+	if err != nil {
+		return "", err
+	}
+	taken := map[string]bool{}
+	for _, t := range tags {
+		taken[strings.TrimPrefix(t.Name, snapshotTagPrefix)] = true
+	}
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	return unique, m.repo.SetTag(snapshotTagPrefix+unique, hash)
+}
+
+// Snapshots of every device matching the filter, oldest first.
+func (m *Link) Snapshots(filter SnapshotFilter) ([]Snapshot, error) {
+	tags, err := m.repo.Tags(snapshotTagPrefix)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := m.pins()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, t := range tags {
+		rev, err := m.repo.Lookup(t.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if !filter.Since.IsZero() && rev.When.Before(filter.Since) || !filter.Until.IsZero() && rev.When.After(filter.Until) {
+			continue
+		}
+		size, err := m.repo.TreeSize(t.Hash, backupDirname)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(t.Name, snapshotTagPrefix)
+		trailers := git.ParseTrailers(rev.Message)
+		device := trailers[TrailerDevice]
+		if device == "" {
+			device = trailers[TrailerHost]
+		}
+		summary, _, _ := strings.Cut(rev.Message, "\n")
+		snapshots = append(snapshots, Snapshot{
+			Name:    name,
+			Hash:    t.Hash,
+			When:    rev.When,
+			Device:  device,
+			Size:    size,
+			Summary: summary,
+			Pinned:  pins[name],
+		})
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].When.Before(snapshots[j].When) })
+	return snapshots, nil
+}
+
+// Pin the snapshot name, so that pruning never removes it.
+func (m *Link) Pin(name string) error {
+	name = strings.TrimPrefix(name, snapshotTagPrefix)
+	tags, err := m.repo.Tags(snapshotTagPrefix + name)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if t.Name == snapshotTagPrefix+name {
+			return m.repo.SetTag(pinTagPrefix+name, t.Hash)
+		}
+	}
+	return errors.Errorf("snapshot %s not found", name)
+}
+
+// Unpin the snapshot name.
+func (m *Link) Unpin(name string) error {
+	name = strings.TrimPrefix(name, snapshotTagPrefix)
+	if err := m.repo.DeleteTag(pinTagPrefix + name); err != nil {
+		return errors.Wrapf(err, "snapshot %s is not pinned", name)
+	}
+	return nil
+}
+
+// Names of the pinned snapshots.
+func (m *Link) pins() (map[string]bool, error) {
+	tags, err := m.repo.Tags(pinTagPrefix)
+	if err != nil {
+		return nil, err
+	}
+	pins := map[string]bool{}
+	for _, t := range tags {
+		pins[strings.TrimPrefix(t.Name, pinTagPrefix)] = true
+	}
+	return pins, nil
+}
+
+// Extract the backup selected by the restore options into dir, and return its manifest, if any.
+func (m *Link) extract(opts RestoreOptions, dir string) (*manifest.Manifest, error) {
+	var hash plumbing.Hash
+	var err error
+	switch {
+	case opts.Revision != "":
+		hash, err = m.resolveRevision(opts.Revision)
+	case !opts.Time.IsZero():
+		hash, err = m.resolveTime(opts.Time, opts.Device)
+	default:
+		if hash, err = m.repo.ResolveBranch(DeviceBranch(opts.Device)); err != nil {
+			err = errors.Wrapf(err, "failed to find backup of device %s", opts.Device)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.repo.Extract(hash, backupDirname, dir); err != nil {
+		return nil, errors.Wrapf(err, "failed to extract backup %s", hash)
+	}
+	data, err := m.repo.ReadFile(hash, manifest.Filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read manifest of backup %s", hash)
+	}
+	return manifest.Parse(data)
+}
+
+// Commit of a snapshot name, a tag or a commit.
+func (m *Link) resolveRevision(rev string) (plumbing.Hash, error) {
+	name := strings.TrimPrefix(rev, snapshotTagPrefix)
+	if tags, err := m.repo.Tags(snapshotTagPrefix + name); err == nil {
+		for _, t := range tags {
+			if t.Name == snapshotTagPrefix+name {
+				return t.Hash, nil
+			}
+		}
+	}
+	return m.repo.ResolveRevision(rev)
+}
+
+// Commit of the last snapshot at time t, of device, else of this device. Without a device, nor any snapshot, the last
+// commit of the current branch at time t.
+func (m *Link) resolveTime(t time.Time, device string) (plumbing.Hash, error) {
+	// Never restore this device from the backup of another one by default.
+	if device == "" {
+		device = m.deviceID
+	}
+	snapshots, err := m.Snapshots(SnapshotFilter{Until: t})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if device == "" || snapshots[i].Device == device {
+			return snapshots[i].Hash, nil
+		}
+	}
+	if device == "" {
+		history, err := m.repo.History()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		for _, rev := range history {
+			if !rev.When.After(t) {
+				return rev.Hash, nil
+			}
+		}
+	}
+	return plumbing.ZeroHash, errors.Errorf("no backup at %s", t.Format(time.RFC3339))
+}
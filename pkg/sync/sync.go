@@ -1,69 +1,285 @@
 package sync
 
 import (
+	"context"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/floriankarydes/notesforever/pkg/export"
 	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/lfs"
+	"github.com/floriankarydes/notesforever/pkg/manifest"
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
 	cp "github.com/otiai10/copy"
 	"github.com/pkg/errors"
 )
 
 type Link struct {
-	repo   *git.Repo
-	srcDir string
+	repo     *git.Repo
+	srcDir   string
+	version  string
+	deviceID string
+	lfs      *lfs.Tracker
+	checksum bool
+	guard    Guard
+	export   export.Format
+	// now names snapshots; tests replace it.
+	now func() time.Time
+}
+
+// Options of a Link.
+type Options struct {
+	// Version of the tool, recorded in commit messages.
+	Version string
+	// DeviceID of this device, recorded in commit messages, when each device backs up to its own branch.
+	DeviceID string
+	// LFS stores large files as LFS pointers, if not nil.
+	LFS *lfs.Tracker
+	// Checksum compares the content of files with the same size and modification time, instead of assuming they did
+	// not change.
+	Checksum bool
+	// Guard quarantines backups deleting too much.
+	Guard Guard
+	// Export writes the notes of each backup to the notes directory of the repository in this format, if not empty.
+	Export export.Format
+}
+
+// RestoreOptions select the backup to restore.
+type RestoreOptions struct {
+	// Device whose last backup is restored. Defaults to the checked out backup.
+	Device string
+	// Revision restored: a snapshot name, a tag or a commit.
+	Revision string
+	// Time restored: the last snapshot at that time, of Device if set.
+	Time time.Time
+	// Target directory the backup is restored into, leaving the notes directory untouched. It must be empty or not
+	// exist yet.
+	Target string
+}
+
+// BackupOptions control the checks made before committing a backup.
+type BackupOptions struct {
+	// Force commits the backup even if a database fails its integrity check, or too much was deleted.
+	Force bool
 }
 
 const backupDirname = "backup"
 
-func New(repo *git.Repo, srcDir string) (*Link, error) {
+func New(repo *git.Repo, srcDir string, opts Options) (*Link, error) {
 	m := &Link{
-		repo:   repo,
-		srcDir: srcDir,
+		repo:     repo,
+		srcDir:   srcDir,
+		version:  opts.Version,
+		deviceID: opts.DeviceID,
+		lfs:      opts.LFS,
+		checksum: opts.Checksum,
+		guard:    opts.Guard,
+		export:   opts.Export,
+		now:      time.Now,
 	}
 	return m, nil
 }
 
-func (m *Link) Backup() error {
+func (m *Link) Backup(opts BackupOptions) error {
 	defer m.repo.Clean()
+	start := time.Now()
 
-	// Clear destination directory.
-	if err := os.RemoveAll(m.dstDir()); err != nil {
-		return errors.Wrap(err, "failed to remove destination directory")
-	}
-	if err := os.MkdirAll(m.dstDir(), git.DirPerm); err != nil {
-		return errors.Wrap(err, "failed to re-create destination directory")
+	// Read metadata first, as copying files changes their access time.
+	entries, err := statFiles(m.srcDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read metadata")
 	}
 
-	// Copy files to destination directory.
-	if err := cp.Copy(m.srcDir, m.dstDir()); err != nil {
+	// Copy the changed files to destination directory. Databases are snapshotted instead, as Notes may be writing.
+	copied, err := mirror(m.srcDir, m.dstDir(), compareOptions{checksum: m.checksum, skip: sqlite.IsDatabaseFile})
+	if err != nil {
 		return errors.Wrap(err, "failed to copy directory")
 	}
+	log.Printf("%d file(s) copied or deleted", len(copied))
+	if err := snapshotDatabases(m.srcDir, m.dstDir()); err != nil {
+		return errors.Wrap(err, "failed to snapshot databases")
+	}
+	// Never replace the last good backup with a corrupt database.
+	problems, err := checkDatabases(m.dstDir())
+	if err != nil {
+		return errors.Wrap(err, "failed to check databases")
+	}
+	if len(problems) > 0 {
+		reason := "corrupt database: " + strings.Join(problems, "; ")
+		if !opts.Force {
+			return m.refuse(start, reason)
+		}
+		log.Printf("%s; committing anyway, as forced", reason)
+	}
+	mf, err := m.writeManifest(entries)
+	if err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+	stats := statsOf(m.dstDir(), mf)
+
+	// Readable notes are a convenience: a backup is better without them than not made.
+	if m.export != "" {
+		if err := m.exportNotes(); err != nil {
+			log.Printf("failed to export notes: %s", err.Error())
+		}
+	}
+
+	// Replace large files with LFS pointers.
+	if m.lfs != nil {
+		paths, err := m.lfs.Clean(m.dstDir(), backupDirname)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(m.notesDir()); err == nil {
+			exported, err := m.lfs.Clean(m.notesDir(), notesDirname)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, exported...)
+		}
+		attributes := filepath.Join(m.repo.Dir(), lfs.AttributesFile)
+		if err := os.WriteFile(attributes, []byte(m.lfs.Attributes(paths)), 0644); err != nil {
+			return errors.Wrap(err, "failed to write LFS attributes")
+		}
+	}
 
-	// Push all changes.
-	if err := m.repo.Push(); err != nil {
-		return errors.Wrap(err, "failed to push changes")
+	// Commit all changes locally, so that history is kept even when offline.
+	changes, err := m.repo.Stage()
+	if err != nil {
+		return errors.Wrap(err, "failed to stage changes")
+	}
+	if len(changes) == 0 {
+		log.Println("no changes to commit")
+	} else {
+		message := m.commitMessage(changes, time.Since(start), stats)
+		// Do not let a sync gone wrong replace the last good backup with a mass deletion.
+		if !opts.Force {
+			prev, ok, err := m.lastStats()
+			if err != nil {
+				return errors.Wrap(err, "failed to read last backup")
+			}
+			if reasons := m.guard.check(prev, stats); ok && len(reasons) > 0 {
+				return m.quarantine(start, message, strings.Join(reasons, "; "))
+			}
+		}
+		hash, err := m.repo.Commit(message)
+		if err != nil {
+			return errors.Wrap(err, "failed to commit changes")
+		}
+		name, err := m.tagSnapshot(hash, m.now())
+		if err != nil {
+			return errors.Wrap(err, "failed to tag snapshot")
+		}
+		log.Printf("snapshot %s", name)
+	}
+
+	// Push this commit and any previous one still pending.
+	if _, err := m.Push(); err != nil {
+		log.Printf("failed to push changes: %s; they will be pushed on next run", err.Error())
 	}
 
 	return nil
 }
 
-func (m *Link) Restore() error {
+// Push the pending commits, after uploading their LFS objects.
+func (m *Link) Push() ([]git.PushResult, error) {
+	if m.lfs != nil {
+		if err := m.lfs.Upload(context.Background()); err != nil {
+			return nil, errors.Wrap(err, "failed to upload LFS objects")
+		}
+	}
+	return m.repo.Push()
+}
+
+func (m *Link) Restore(opts RestoreOptions) error {
+
+	fromDir, mf, cleanup, err := m.restoreSource(opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
-	// Clear src directory.
-	if err := os.Rename(m.srcDir, m.saveDir()); err != nil {
+	toDir := m.srcDir
+	if opts.Target != "" {
+		if err := checkTarget(opts.Target, m.srcDir); err != nil {
+			return err
+		}
+		toDir = opts.Target
+	} else if err := os.Rename(m.srcDir, m.saveDir()); err != nil {
+		// Clear src directory.
 		return errors.Wrap(err, "failed to save source directory")
 	}
-	if err := os.MkdirAll(m.srcDir, git.DirPerm); err != nil {
-		return errors.Wrap(err, "failed to re-create source directory")
+	if err := os.MkdirAll(toDir, git.DirPerm); err != nil {
+		return errors.Wrap(err, "failed to create restore directory")
 	}
 
 	// Copy files from Git repository.
-	if err := cp.Copy(m.dstDir(), m.srcDir); err != nil {
+	if err := cp.Copy(fromDir, toDir); err != nil {
 		return errors.Wrap(err, "failed to copy directory")
 	}
 
+	// Bring back the content of LFS pointers.
+	if m.lfs != nil {
+		if err := m.lfs.Smudge(context.Background(), toDir); err != nil {
+			return errors.Wrap(err, "failed to restore LFS objects")
+		}
+	}
+
+	// Bring back the modes, times and extended attributes Git does not keep.
+	if mf != nil {
+		if err := mf.Apply(toDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Directory holding the backup to restore, its manifest if any, and a function removing the directory if temporary.
+func (m *Link) restoreSource(opts RestoreOptions) (string, *manifest.Manifest, func(), error) {
+	if opts.Revision == "" && opts.Time.IsZero() && opts.Device == "" {
+		mf, err := m.worktreeManifest()
+		if err != nil {
+			return "", nil, nil, errors.Wrap(err, "failed to read manifest")
+		}
+		return m.dstDir(), mf, func() {}, nil
+	}
+	// Extract a past backup, or the backup of another device, from Git objects.
+	tmpDir, err := os.MkdirTemp("", "notesforever-restore-")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+	mf, err := m.extract(opts, tmpDir)
+	if err != nil {
+		cleanup()
+		return "", nil, nil, err
+	}
+	return tmpDir, mf, cleanup, nil
+}
+
+// Check that the target of a restore is an empty directory, or does not exist, and is not the notes directory.
+func checkTarget(target, srcDir string) error {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	if src, err := filepath.Abs(srcDir); err == nil && abs == src {
+		return errors.New("restore target cannot be the notes directory")
+	}
+	entries, err := os.ReadDir(abs)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read restore target")
+	}
+	if len(entries) > 0 {
+		return errors.Errorf("restore target %s is not empty", target)
+	}
 	return nil
 }
 
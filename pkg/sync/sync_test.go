@@ -0,0 +1,723 @@
+package sync
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/floriankarydes/notesforever/pkg/copy"
+	"github.com/floriankarydes/notesforever/pkg/export"
+	"github.com/floriankarydes/notesforever/pkg/git"
+	"github.com/floriankarydes/notesforever/pkg/lfs"
+	"github.com/floriankarydes/notesforever/pkg/manifest"
+	"github.com/floriankarydes/notesforever/pkg/retention"
+	gogit "github.com/go-git/go-git/v5"
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	cp "github.com/otiai10/copy"
+	"github.com/pkg/xattr"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// Open a link between a notes directory and a repository with an empty bare remote.
+func newTestLink(t *testing.T, opts Options) *Link {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	assert.NilError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = test\n\temail = test@example.com\n"), 0644))
+
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	_, err := gogit.PlainInit(bare, true)
+	assert.NilError(t, err)
+	dir := filepath.Join(t.TempDir(), "repo")
+	_, err = gogit.PlainInit(dir, false)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("notes\n"), 0644))
+	repo, err := git.Load(dir, git.Options{URL: bare, Tags: TagPrefixes(opts.DeviceID), Retry: git.RetryOptions{Attempts: 1}})
+	assert.NilError(t, err)
+	_, err = repo.Stage()
+	assert.NilError(t, err)
+	_, err = repo.Commit("init")
+	assert.NilError(t, err)
+
+	srcDir := filepath.Join(t.TempDir(), "notes")
+	assert.NilError(t, os.MkdirAll(srcDir, 0755))
+	link, err := New(repo, srcDir, opts)
+	assert.NilError(t, err)
+	return link
+}
+
+// Write a note and back it up.
+func backupNote(t *testing.T, m *Link, name, content string) {
+	t.Helper()
+	assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, name), []byte(content), 0644))
+	assert.NilError(t, m.Backup(BackupOptions{}))
+}
+
+func TestSnapshots(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test", DeviceID: "mac"})
+	m.now = func() time.Time { return time.Date(2026, 10, 16, 8, 30, 15, 0, time.Local) }
+	backupNote(t, m, "a.txt", "a")
+	backupNote(t, m, "b.txt", "bb")
+	backupNote(t, m, "c.txt", "ccc")
+
+	snapshots, err := m.Snapshots(SnapshotFilter{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(snapshots, 3))
+	// Backups in the same minute get a suffix.
+	assert.Check(t, is.Equal(snapshots[0].Name, "mac/2026-10-16T08-30"))
+	assert.Check(t, is.Equal(snapshots[1].Name, "mac/2026-10-16T08-30-2"))
+	assert.Check(t, is.Equal(snapshots[2].Name, "mac/2026-10-16T08-30-3"))
+	assert.Check(t, is.Equal(snapshots[0].Device, "mac"))
+	assert.Check(t, is.Equal(snapshots[0].Summary, "Backup: 1 added (+1 B)"))
+	assert.Check(t, is.Equal(snapshots[2].Size, int64(6)))
+
+	filtered, err := m.Snapshots(SnapshotFilter{Until: snapshots[0].When.Add(-time.Second)})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(filtered, 0))
+
+	// Pinned snapshots survive pruning, and the tags follow the rewritten history.
+	assert.NilError(t, m.Pin(snapshots[0].Name))
+	res, err := m.Prune(PruneOptions{Policy: retention.Policy{}})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(res.Kept, 2))
+	assert.Check(t, is.Equal(res.Dropped, 2))
+	pruned, err := m.Snapshots(SnapshotFilter{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(pruned, 2))
+	assert.Check(t, is.Equal(pruned[0].Name, snapshots[0].Name))
+	assert.Check(t, pruned[0].Pinned)
+	assert.Check(t, pruned[0].Hash != snapshots[0].Hash)
+	assert.Check(t, is.Equal(pruned[1].Name, snapshots[2].Name))
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(history[0].Hash, pruned[1].Hash))
+	assert.Check(t, is.Equal(history[1].Hash, pruned[0].Hash))
+}
+
+func TestPrunePushed(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	gitRepo, err := gogit.PlainOpen(m.repo.Dir())
+	assert.NilError(t, err)
+	_, err = gitRepo.CreateRemote(&gogitconfig.RemoteConfig{Name: gogit.DefaultRemoteName, URLs: []string{m.repo.URL()}})
+	assert.NilError(t, err)
+	backupNote(t, m, "a.txt", "a")
+	backupNote(t, m, "b.txt", "b")
+	backupNote(t, m, "c.txt", "c")
+	_, err = m.repo.Push()
+	assert.NilError(t, err)
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+
+	// Pruning pushed backups without overwriting them on the remote is refused.
+	for _, dryRun := range []bool{true, false} {
+		_, err = m.Prune(PruneOptions{Policy: retention.Policy{}, DryRun: dryRun})
+		assert.Check(t, is.ErrorIs(err, ErrRemoteHistory))
+	}
+	after, err := m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(after, history))
+
+	res, err := m.Prune(PruneOptions{Policy: retention.Policy{}, ForcePush: true})
+	assert.NilError(t, err)
+	assert.Check(t, res.Pushed)
+	remote, err := m.repo.RemoteHead(gogit.DefaultRemoteName)
+	assert.NilError(t, err)
+	after, err = m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(remote, after[0].Hash))
+}
+
+func TestRestoreAt(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	backupNote(t, m, "a.txt", "a")
+	first, err := m.repo.History()
+	assert.NilError(t, err)
+	backupNote(t, m, "a.txt", "a2")
+	backupNote(t, m, "b.txt", "b")
+	snapshots, err := m.Snapshots(SnapshotFilter{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(snapshots, 3))
+
+	// The notes directory is saved in the working directory.
+	wd, err := os.Getwd()
+	assert.NilError(t, err)
+	saved := t.TempDir()
+	assert.NilError(t, os.Chdir(saved))
+	defer os.Chdir(wd)
+
+	readNotes := func() map[string]string {
+		notes := map[string]string{}
+		entries, err := os.ReadDir(m.srcDir)
+		assert.NilError(t, err)
+		for _, e := range entries {
+			content, err := os.ReadFile(filepath.Join(m.srcDir, e.Name()))
+			assert.NilError(t, err)
+			notes[e.Name()] = string(content)
+		}
+		return notes
+	}
+	for _, opts := range []RestoreOptions{
+		{Revision: snapshots[0].Name},
+		{Revision: "snapshot/" + snapshots[0].Name},
+		{Revision: first[0].Hash.String()[:10]},
+	} {
+		assert.NilError(t, m.Restore(opts))
+		assert.Check(t, is.DeepEqual(readNotes(), map[string]string{"a.txt": "a"}), opts)
+		assert.NilError(t, os.RemoveAll(filepath.Join(saved, m.saveDir())))
+	}
+	assert.NilError(t, m.Restore(RestoreOptions{Time: time.Now().Add(time.Hour)}))
+	assert.Check(t, is.DeepEqual(readNotes(), map[string]string{"a.txt": "a2", "b.txt": "b"}))
+	_, err = m.repo.ResolveRevision("unknown")
+	assert.Check(t, err != nil)
+
+	// The worktree keeps the last backup.
+	content, err := os.ReadFile(filepath.Join(m.repo.Dir(), backupDirname, "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "a2"))
+}
+
+func TestRestoreAtOwnDevice(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test", DeviceID: "mac"})
+	other, err := New(m.repo, filepath.Join(t.TempDir(), "notes"), Options{Version: "test", DeviceID: "other"})
+	assert.NilError(t, err)
+	assert.NilError(t, os.MkdirAll(other.srcDir, 0755))
+	backupNote(t, m, "a.txt", "mac")
+	backupNote(t, other, "a.txt", "other")
+
+	// The last snapshot is of the other device, but this one restores its own.
+	target := filepath.Join(t.TempDir(), "restored")
+	assert.NilError(t, m.Restore(RestoreOptions{Time: time.Now().Add(time.Hour), Target: target}))
+	content, err := os.ReadFile(filepath.Join(target, "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "mac"))
+
+	target = filepath.Join(t.TempDir(), "restored")
+	assert.NilError(t, m.Restore(RestoreOptions{Time: time.Now().Add(time.Hour), Device: "other", Target: target}))
+	content, err = os.ReadFile(filepath.Join(target, "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "other"))
+}
+
+func TestRestoreTarget(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	backupNote(t, m, "a.txt", "a")
+	backupNote(t, m, "a.txt", "a2")
+	assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, "a.txt"), []byte("live"), 0644))
+	snapshots, err := m.Snapshots(SnapshotFilter{})
+	assert.NilError(t, err)
+
+	target := filepath.Join(t.TempDir(), "inspect")
+	assert.NilError(t, m.Restore(RestoreOptions{Revision: snapshots[0].Name, Target: target}))
+	content, err := os.ReadFile(filepath.Join(target, "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "a"))
+
+	// The notes directory is left untouched.
+	content, err = os.ReadFile(filepath.Join(m.srcDir, "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "live"))
+
+	err = m.Restore(RestoreOptions{Target: target})
+	assert.Check(t, is.ErrorContains(err, "is not empty"))
+	err = m.Restore(RestoreOptions{Target: m.srcDir})
+	assert.Check(t, is.ErrorContains(err, "cannot be the notes directory"))
+}
+
+func TestPlan(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	backupNote(t, m, "a.txt", "a")
+	backupNote(t, m, "c.txt", "ccc")
+	assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, "a.txt"), []byte("a2"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, "b.txt"), []byte("b"), 0644))
+	assert.NilError(t, os.Remove(filepath.Join(m.srcDir, "c.txt")))
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+
+	plan, err := m.PlanBackup()
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(plan, []git.Change{
+		{Path: "a.txt", Action: git.Modified, Size: 2, Delta: 1},
+		{Path: "b.txt", Action: git.Added, Size: 1, Delta: 1},
+		{Path: "c.txt", Action: git.Deleted, Delta: -3},
+	}))
+	assert.Check(t, is.Equal(FormatPlan(plan), "1 added, 1 modified, 1 deleted (-1 B)\n"+
+		"M a.txt 2 B (+1 B)\nA b.txt 1 B (+1 B)\nD c.txt 0 B (-3 B)\n"))
+
+	// Restoring is the reverse plan.
+	plan, err = m.PlanRestore(RestoreOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(plan, []git.Change{
+		{Path: "a.txt", Action: git.Modified, Size: 1, Delta: -1},
+		{Path: "b.txt", Action: git.Deleted, Delta: -1},
+		{Path: "c.txt", Action: git.Added, Size: 3, Delta: 3},
+	}))
+	plan, err = m.PlanRestore(RestoreOptions{Revision: history[1].Hash.String(), Target: filepath.Join(t.TempDir(), "new")})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(plan, []git.Change{{Path: "a.txt", Action: git.Added, Size: 1, Delta: 1}}))
+
+	// Nothing changed.
+	after, err := m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(after[0].Hash, history[0].Hash))
+	content, err := os.ReadFile(filepath.Join(m.srcDir, "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "a2"))
+}
+
+func TestIncrementalBackup(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		m := newTestLink(t, Options{Version: "test", Checksum: checksum})
+		// The database is not a real one.
+		backup := func() error { return m.Backup(BackupOptions{Force: true}) }
+		write := func(name, content string) {
+			path := filepath.Join(m.srcDir, filepath.FromSlash(name))
+			assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+		}
+		write("NoteStore.sqlite", "db")
+		write("Media/a/scan.jpg", "scan")
+		write("Media/b/memo.m4a", "memo")
+		write("Previews/x", "file")
+		assert.NilError(t, backup())
+		assert.Check(t, is.Equal(backupTree(t, m), fullCopyTree(t, m.srcDir)))
+		untouched, err := os.Stat(filepath.Join(m.dstDir(), "Media", "a", "scan.jpg"))
+		assert.NilError(t, err)
+
+		write("NoteStore.sqlite", "db2")
+		assert.NilError(t, os.RemoveAll(filepath.Join(m.srcDir, "Media", "b")))
+		assert.NilError(t, os.RemoveAll(filepath.Join(m.srcDir, "Previews")))
+		write("Previews", "now a file")
+		write("Media/c/new.pdf", "pdf")
+		assert.NilError(t, backup())
+		assert.Check(t, is.Equal(backupTree(t, m), fullCopyTree(t, m.srcDir)))
+		after, err := os.Stat(filepath.Join(m.dstDir(), "Media", "a", "scan.jpg"))
+		assert.NilError(t, err)
+		assert.Check(t, os.SameFile(untouched, after))
+		_, err = os.Stat(filepath.Join(m.dstDir(), "Media", "b"))
+		assert.Check(t, os.IsNotExist(err))
+
+		// Same size and modification time: only a checksum sees the change.
+		path := filepath.Join(m.srcDir, "Media", "a", "scan.jpg")
+		info, err := os.Stat(path)
+		assert.NilError(t, err)
+		write("Media/a/scan.jpg", "scam")
+		assert.NilError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+		assert.NilError(t, backup())
+		assert.Check(t, is.Equal(backupTree(t, m) == fullCopyTree(t, m.srcDir), checksum))
+	}
+}
+
+// Hash of the backup directory tree at HEAD.
+func backupTree(t *testing.T, m *Link) string {
+	t.Helper()
+	gitRepo, err := gogit.PlainOpen(m.repo.Dir())
+	assert.NilError(t, err)
+	head, err := gitRepo.Head()
+	assert.NilError(t, err)
+	commit, err := gitRepo.CommitObject(head.Hash())
+	assert.NilError(t, err)
+	tree, err := commit.Tree()
+	assert.NilError(t, err)
+	sub, err := tree.Tree(backupDirname)
+	assert.NilError(t, err)
+	return sub.Hash.String()
+}
+
+// Hash of the tree of a full copy of src in the backup directory of a new repository.
+func fullCopyTree(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	gitRepo, err := gogit.PlainInit(dir, false)
+	assert.NilError(t, err)
+	assert.NilError(t, cp.Copy(src, filepath.Join(dir, backupDirname)))
+	w, err := gitRepo.Worktree()
+	assert.NilError(t, err)
+	assert.NilError(t, w.AddWithOptions(&gogit.AddOptions{All: true}))
+	hash, err := w.Commit("full", &gogit.CommitOptions{})
+	assert.NilError(t, err)
+	commit, err := gitRepo.CommitObject(hash)
+	assert.NilError(t, err)
+	tree, err := commit.Tree()
+	assert.NilError(t, err)
+	sub, err := tree.Tree(backupDirname)
+	assert.NilError(t, err)
+	return sub.Hash.String()
+}
+
+func TestBackupDatabase(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	db, err := sql.Open("sqlite", filepath.Join(m.srcDir, "NoteStore.sqlite"))
+	assert.NilError(t, err)
+	defer db.Close()
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA wal_autocheckpoint=0",
+		"CREATE TABLE note (body TEXT)",
+		"INSERT INTO note VALUES ('in the write-ahead log')",
+	} {
+		_, err := db.Exec(stmt)
+		assert.NilError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(m.srcDir, "NoteStore.sqlite-wal"))
+	assert.NilError(t, err)
+
+	plan, err := m.PlanBackup()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(plan, 1))
+	assert.Check(t, is.Equal(plan[0].Path, "NoteStore.sqlite"))
+	assert.NilError(t, m.Backup(BackupOptions{}))
+	entries, err := os.ReadDir(m.dstDir())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 1))
+
+	snapshot, err := sql.Open("sqlite", filepath.Join(m.dstDir(), "NoteStore.sqlite"))
+	assert.NilError(t, err)
+	defer snapshot.Close()
+	var body string
+	assert.NilError(t, snapshot.QueryRow("SELECT body FROM note").Scan(&body))
+	assert.Check(t, is.Equal(body, "in the write-ahead log"))
+
+	// An unchanged database gives an identical snapshot.
+	plan, err = m.PlanBackup()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(plan, 0))
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	note := filepath.Join(m.srcDir, "Accounts", "note.txt")
+	assert.NilError(t, os.MkdirAll(filepath.Dir(note), 0700))
+	assert.NilError(t, os.WriteFile(note, []byte("note"), 0600))
+	xattrs := xattr.LSet(note, "user.notesforever", []byte("kept")) == nil
+	assert.NilError(t, os.Symlink("note.txt", filepath.Join(m.srcDir, "Accounts", "link")))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	atime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NilError(t, os.Chtimes(note, atime, mtime))
+	assert.NilError(t, os.Chtimes(filepath.Dir(note), atime, mtime))
+	assert.NilError(t, m.Backup(BackupOptions{}))
+
+	mf, err := manifest.Read(filepath.Join(m.repo.Dir(), manifest.Filename))
+	assert.NilError(t, err)
+	e, ok := mf.Lookup("Accounts/note.txt")
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(e.Size, int64(4)))
+	assert.Check(t, is.Equal(e.SHA256, "edb465624291e4053c6c5ea4b7eb320dec773e10a57d26b95dcf0564f8e310f8"))
+	assert.Check(t, is.Equal(e.Mode, "0600"))
+	assert.Check(t, e.Mtime.Equal(mtime))
+	e, ok = mf.Lookup("Accounts/link")
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(e.Type, manifest.Symlink))
+
+	// Reading files changes their access time, but is not a change.
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+	_, err = os.ReadFile(note)
+	assert.NilError(t, err)
+	assert.NilError(t, m.Backup(BackupOptions{}))
+	after, err := m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(after[0].Hash, history[0].Hash))
+
+	// Git does not keep them, the restore brings them back.
+	target := filepath.Join(t.TempDir(), "restored")
+	assert.NilError(t, m.Restore(RestoreOptions{Revision: history[0].Hash.String(), Target: target}))
+	for _, name := range []string{"Accounts", "Accounts/note.txt"} {
+		want, err := copy.ReadMetadata(filepath.Join(m.srcDir, name))
+		assert.NilError(t, err)
+		got, err := copy.ReadMetadata(filepath.Join(target, name))
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(got.Mode, want.Mode), name)
+		assert.Check(t, got.Mtime.Equal(mtime), name)
+		assert.Check(t, got.Atime.Equal(atime), name)
+		if xattrs && name != "Accounts" {
+			assert.Check(t, is.DeepEqual(got.Xattrs, want.Xattrs), name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	db, err := sql.Open("sqlite", filepath.Join(m.srcDir, "NoteStore.sqlite"))
+	assert.NilError(t, err)
+	_, err = db.Exec("CREATE TABLE note (body TEXT)")
+	assert.NilError(t, err)
+	assert.NilError(t, db.Close())
+	backupNote(t, m, "a.txt", "a")
+	backupNote(t, m, "b.txt", "b")
+
+	res, err := m.Verify("")
+	assert.NilError(t, err)
+	assert.Check(t, res.Manifest)
+	assert.Check(t, is.Len(res.Problems, 0))
+	assert.Check(t, is.Equal(res.Files, 3))
+	assert.Check(t, is.Equal(res.Databases, 1))
+
+	// A database SQLite cannot read, committed anyway.
+	assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, "Broken.sqlite"), []byte("not a database"), 0644))
+	assert.NilError(t, m.Backup(BackupOptions{Force: true}))
+	res, err = m.Verify("")
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(res.Problems, 1))
+	assert.Check(t, is.Contains(res.Problems[0], "Broken.sqlite: "))
+
+	// An object whose content does not match its hash.
+	gitRepo, err := gogit.PlainOpen(m.repo.Dir())
+	assert.NilError(t, err)
+	commit, err := gitRepo.CommitObject(res.Hash)
+	assert.NilError(t, err)
+	objectPath := func(name string) string {
+		f, err := commit.File(name)
+		assert.NilError(t, err)
+		hash := f.Hash.String()
+		return filepath.Join(m.repo.Dir(), ".git", "objects", hash[:2], hash[2:])
+	}
+	corrupt, err := os.ReadFile(objectPath("backup/b.txt"))
+	assert.NilError(t, err)
+	assert.NilError(t, os.Chmod(objectPath("backup/a.txt"), 0644))
+	assert.NilError(t, os.WriteFile(objectPath("backup/a.txt"), corrupt, 0644))
+	snapshots, err := m.Snapshots(SnapshotFilter{})
+	assert.NilError(t, err)
+	res, err = m.Verify(snapshots[0].Name)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(res.Problems, 1))
+	assert.Check(t, is.Contains(res.Problems[0], "backup/a.txt: corrupt object"))
+}
+
+func TestCheckDatabaseWithWAL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "NoteStore.sqlite")
+	db, err := sql.Open("sqlite", path)
+	assert.NilError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA wal_autocheckpoint = 0",
+		"CREATE TABLE note (body TEXT)",
+		"INSERT INTO note VALUES ('a'), ('b')",
+		"PRAGMA wal_checkpoint(TRUNCATE)",
+		"UPDATE note SET body = 'c'",
+	} {
+		_, err := db.Exec(stmt)
+		assert.NilError(t, err)
+	}
+	// Copied as they are, while the last changes are only in the write-ahead log.
+	copyDir := t.TempDir()
+	copied := filepath.Join(copyDir, "NoteStore.sqlite")
+	for _, ext := range []string{"", "-wal", "-shm"} {
+		assert.NilError(t, copyFile(path+ext, copied+ext))
+	}
+	// The table page of the database file is stale garbage, superseded by the log.
+	f, err := os.OpenFile(copied, os.O_WRONLY, 0)
+	assert.NilError(t, err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xff}, 512), 4096)
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	problems, err := checkDatabases(copyDir)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(problems, 0))
+	problems, err = checkDatabase(copied, "", "")
+	assert.NilError(t, err)
+	assert.Check(t, len(problems) > 0)
+}
+
+func TestVerifyMissingLFSObject(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test", LFS: &lfs.Tracker{
+		Filter: lfs.Filter{Patterns: []string{"*.pdf"}},
+		Store:  lfs.NewStore(t.TempDir()),
+	}})
+	backupNote(t, m, "a.txt", "a")
+	backupNote(t, m, "scan.pdf", "scan")
+
+	res, err := m.Verify("")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(res.Problems, 0))
+	assert.Check(t, is.Len(res.Unverified, 0))
+	assert.Check(t, is.Equal(res.Files, 2))
+
+	// The content of an object missing from the local store cannot be checked.
+	pointers, err := m.lfs.Store.Pointers()
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(pointers, 1))
+	assert.NilError(t, os.Remove(m.lfs.Store.Path(pointers[0].Oid)))
+	res, err = m.Verify("")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(res.Problems, 0))
+	assert.Check(t, is.DeepEqual(res.Unverified, []string{"scan.pdf"}))
+	assert.Check(t, is.Equal(res.Files, 1))
+}
+
+func TestRefuseCorruptDatabase(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test"})
+	path := filepath.Join(m.srcDir, "NoteStore.sqlite")
+	db, err := sql.Open("sqlite", path)
+	assert.NilError(t, err)
+	_, err = db.Exec("CREATE TABLE note (body TEXT)")
+	assert.NilError(t, err)
+	assert.NilError(t, db.Close())
+	assert.NilError(t, m.Backup(BackupOptions{}))
+	good, err := os.ReadFile(filepath.Join(m.dstDir(), "NoteStore.sqlite"))
+	assert.NilError(t, err)
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+
+	// A truncated database is refused, and the last good backup is kept.
+	assert.NilError(t, os.Truncate(path, 100))
+	assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, "a.txt"), []byte("a"), 0644))
+	err = m.Backup(BackupOptions{})
+	var refused *RefusedError
+	assert.Assert(t, errors.As(err, &refused))
+	assert.Check(t, is.Contains(refused.Reason, "NoteStore.sqlite: "))
+	after, err := m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(after[0].Hash, history[0].Hash))
+	content, err := os.ReadFile(filepath.Join(m.dstDir(), "NoteStore.sqlite"))
+	assert.NilError(t, err)
+	assert.Check(t, bytes.Equal(content, good))
+	_, err = os.Stat(filepath.Join(m.dstDir(), "a.txt"))
+	assert.Check(t, os.IsNotExist(err))
+	when, reason, err := LastFailure(m.repo)
+	assert.NilError(t, err)
+	assert.Check(t, !when.IsZero())
+	assert.Check(t, is.Equal(reason, refused.Reason))
+
+	// Unless forced.
+	assert.NilError(t, m.Backup(BackupOptions{Force: true}))
+	after, err = m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, after[0].Hash != history[0].Hash)
+}
+
+func TestQuarantine(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test", Guard: Guard{Notes: 0.1, Files: 0.25, Bytes: 0.25}})
+	db, err := sql.Open("sqlite", filepath.Join(m.srcDir, noteStoreFilename))
+	assert.NilError(t, err)
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE ZICCLOUDSYNCINGOBJECT (Z_PK INTEGER PRIMARY KEY, ZNOTEDATA INTEGER, ZMARKEDFORDELETION INTEGER)",
+		"INSERT INTO ZICCLOUDSYNCINGOBJECT (ZNOTEDATA) VALUES (1), (2), (3), (4), (5), (6), (7), (8), (9), (10), (NULL)",
+	} {
+		_, err := db.Exec(stmt)
+		assert.NilError(t, err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, name), []byte("note"), 0644))
+	}
+	assert.NilError(t, m.Backup(BackupOptions{}))
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+	stats, ok := parseStats(git.ParseTrailers(history[0].Message))
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(stats.Notes, int64(10)))
+	assert.Check(t, is.Equal(stats.Files, int64(4)))
+
+	// A few deletions are fine.
+	_, err = db.Exec("UPDATE ZICCLOUDSYNCINGOBJECT SET ZMARKEDFORDELETION = 1 WHERE Z_PK = 1")
+	assert.NilError(t, err)
+	assert.NilError(t, m.Backup(BackupOptions{}))
+	history, err = m.repo.History()
+	assert.NilError(t, err)
+
+	// Half of the notes are gone: the backup goes to a quarantine branch.
+	_, err = db.Exec("DELETE FROM ZICCLOUDSYNCINGOBJECT WHERE Z_PK <= 5")
+	assert.NilError(t, err)
+	err = m.Backup(BackupOptions{})
+	var quarantined *QuarantinedError
+	assert.Assert(t, errors.As(err, &quarantined))
+	assert.Check(t, is.Equal(quarantined.Reason, "notes dropped by 44%, from 9 to 5"))
+	assert.Check(t, strings.HasPrefix(quarantined.Branch, quarantineBranchPrefix))
+	after, err := m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(after[0].Hash, history[0].Hash))
+	hash, err := m.repo.ResolveBranch(quarantined.Branch)
+	assert.NilError(t, err)
+	rev, err := m.repo.Lookup(hash)
+	assert.NilError(t, err)
+	stats, _ = parseStats(git.ParseTrailers(rev.Message))
+	assert.Check(t, is.Equal(stats.Notes, int64(5)))
+	_, reason, err := LastFailure(m.repo)
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(reason, quarantined.Branch))
+
+	// So do mass file deletions.
+	for _, name := range []string{"a.txt", "b.txt"} {
+		assert.NilError(t, os.Remove(filepath.Join(m.srcDir, name)))
+	}
+	err = m.Backup(BackupOptions{})
+	assert.Assert(t, errors.As(err, &quarantined))
+	assert.Check(t, is.Contains(quarantined.Reason, "files dropped by 50%, from 4 to 2"))
+
+	// Unless forced.
+	assert.NilError(t, m.Backup(BackupOptions{Force: true}))
+	after, err = m.repo.History()
+	assert.NilError(t, err)
+	assert.Check(t, after[0].Hash != history[0].Hash)
+}
+
+func TestQuarantineAfterMerge(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test", Guard: Guard{Files: 0.25}})
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(m.srcDir, name), []byte("note"), 0644))
+	}
+	assert.NilError(t, m.Backup(BackupOptions{}))
+	// A commit without counts on top of the last backup, like the merge of a diverged remote history.
+	assert.NilError(t, os.WriteFile(filepath.Join(m.repo.Dir(), "README.md"), []byte("merged\n"), 0644))
+	_, err := m.repo.Stage()
+	assert.NilError(t, err)
+	_, err = m.repo.Commit("Merge remote history")
+	assert.NilError(t, err)
+
+	// The guard still compares with the last backup.
+	for _, name := range []string{"a.txt", "b.txt"} {
+		assert.NilError(t, os.Remove(filepath.Join(m.srcDir, name)))
+	}
+	err = m.Backup(BackupOptions{})
+	var quarantined *QuarantinedError
+	assert.Assert(t, errors.As(err, &quarantined))
+	assert.Check(t, is.Equal(quarantined.Reason, "files dropped by 50%, from 4 to 2"))
+}
+
+func TestExportNotes(t *testing.T) {
+	m := newTestLink(t, Options{Version: "test", Export: export.Markdown})
+	assert.NilError(t, copyFile(filepath.Join("..", "notestore", "testdata", "modern.sqlite"), filepath.Join(m.srcDir, noteStoreFilename)))
+	media := filepath.Join(m.srcDir, "Accounts", "A1B2C3D4-ICLOUD", "Media", "MEDIA-PHOTO", "1_0F7A", "cake.jpeg")
+	assert.NilError(t, os.MkdirAll(filepath.Dir(media), 0755))
+	assert.NilError(t, os.WriteFile(media, []byte("JPEG"), 0644))
+	assert.NilError(t, m.Backup(BackupOptions{}))
+
+	history, err := m.repo.History()
+	assert.NilError(t, err)
+	cake := "notes/iCloud/Recipes/Desserts/Chocolate cake.md"
+	data, err := m.repo.ReadFile(history[0].Hash, cake)
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(data), "![cake.jpeg](Chocolate%20cake%20attachments/cake.jpeg)"))
+	data, err = m.repo.ReadFile(history[0].Hash, "notes/iCloud/Recipes/Desserts/Chocolate cake attachments/cake.jpeg")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(data), "JPEG"))
+	// The summary counts the files of the Notes app only, and the body lists the notes.
+	assert.Check(t, is.Contains(history[0].Message, "Backup: 2 added"))
+	assert.Check(t, is.Contains(history[0].Message, "\nA Chocolate cake (iCloud/Recipes/Desserts)\n"))
+	assert.Check(t, !strings.Contains(history[0].Message, "attachments"))
+
+	// Notes are exported from a past backup, or the current ones.
+	assert.NilError(t, os.Remove(media))
+	target := filepath.Join(t.TempDir(), "export")
+	n, err := m.Export(ExportOptions{Format: export.Markdown, Target: target, Revision: history[0].Hash.String()})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, 4))
+	_, err = os.Stat(filepath.Join(target, "iCloud", "Recipes", "Desserts", "Chocolate cake attachments", "cake.jpeg"))
+	assert.NilError(t, err)
+	_, err = m.Export(ExportOptions{Format: export.Markdown, Target: target})
+	assert.Check(t, is.ErrorContains(err, "not empty"))
+	current := filepath.Join(t.TempDir(), "current")
+	n, err = m.Export(ExportOptions{Format: export.Markdown, Target: current})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n, 4))
+}
@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/floriankarydes/notesforever/pkg/lfs"
+	"github.com/floriankarydes/notesforever/pkg/manifest"
+	"github.com/floriankarydes/notesforever/pkg/sqlite"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+)
+
+// VerifyResult reports the integrity of a backup.
+type VerifyResult struct {
+	// Hash of the verified commit.
+	Hash plumbing.Hash
+	// Files checked, and Databases checked by SQLite.
+	Files, Databases int
+	// Unverified files, stored with LFS, whose content is not in the local LFS store and was not checked.
+	Unverified []string
+	// Manifest is false for backups made before manifests were written, whose content hashes are not checked.
+	Manifest bool
+	// Problems found, one per line. A sound backup has none.
+	Problems []string
+}
+
+// Verify checks the backup at revision, a snapshot name, a tag or a commit, or the last backup if empty: its objects
+// are read back from the object store, its files are hashed against its manifest, and its databases are checked by
+// SQLite. Content stored with LFS is checked if present in the local LFS store, and reported unverified otherwise.
+func (m *Link) Verify(revision string) (*VerifyResult, error) {
+	if revision == "" {
+		revision = "HEAD"
+	}
+	hash, err := m.resolveRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	res := &VerifyResult{Hash: hash}
+	if res.Problems, err = m.repo.Verify(hash); err != nil {
+		return nil, err
+	}
+	if len(res.Problems) > 0 {
+		// Files cannot be read back from a corrupt tree.
+		return res, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "notesforever-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := m.repo.Extract(hash, backupDirname, tmpDir); err != nil {
+		return nil, errors.Wrapf(err, "failed to extract backup %s", hash)
+	}
+	var mf *manifest.Manifest
+	data, err := m.repo.ReadFile(hash, manifest.Filename)
+	if err == nil {
+		if mf, err = manifest.Parse(data); err != nil {
+			res.Problems = append(res.Problems, fmt.Sprintf("%s: %s", manifest.Filename, err))
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.Manifest = mf != nil
+
+	seen := map[string]bool{}
+	err = filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tmpDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		seen[name] = true
+		problems, database, err := m.verifyFile(path, name, info, mf)
+		if errors.Is(err, errUnverified) {
+			res.Unverified = append(res.Unverified, name)
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to verify %s", name)
+		}
+		res.Files++
+		for _, p := range problems {
+			res.Problems = append(res.Problems, fmt.Sprintf("%s: %s", name, p))
+		}
+		if database {
+			res.Databases++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mf != nil {
+		for _, e := range mf.Entries {
+			if e.Type != manifest.Dir && !seen[e.Path] {
+				res.Problems = append(res.Problems, fmt.Sprintf("%s: missing from the backup", e.Path))
+			}
+		}
+	}
+	return res, nil
+}
+
+// errUnverified is returned by verifyFile for an LFS pointer whose object is not in the local LFS store.
+var errUnverified = errors.New("LFS object not in the local store")
+
+// Verify the backed up file at path, named name, against its manifest entry, and check it if it is a database. It
+// returns the problems found, and whether a database was checked.
+func (m *Link) verifyFile(path, name string, info os.FileInfo, mf *manifest.Manifest) ([]string, bool, error) {
+	var e manifest.Entry
+	if mf != nil {
+		var ok bool
+		if e, ok = mf.Lookup(name); !ok {
+			return []string{"not in the manifest"}, false, nil
+		}
+	}
+	if !info.Mode().IsRegular() {
+		if mf != nil && e.Type != manifest.Symlink {
+			return []string{fmt.Sprintf("is a symlink, the manifest has a %s", e.Type)}, false, nil
+		}
+		return nil, false, nil
+	}
+
+	// The content of LFS pointers is in the LFS store.
+	content := path
+	size, sum := info.Size(), ""
+	if p, err := lfs.ReadPointerFile(path); err == nil {
+		if m.lfs == nil || !m.lfs.Store.Has(p.Oid) {
+			// The manifest was built from the pointer, so comparing them would check nothing.
+			return nil, false, errUnverified
+		}
+		size, sum = p.Size, p.Oid
+		content = m.lfs.Store.Path(p.Oid)
+		stored, err := fileHash(content)
+		if err != nil {
+			return nil, false, err
+		}
+		if stored != p.Oid {
+			return []string{fmt.Sprintf("LFS object %s is corrupt, its content hashes to %s", p.Oid, stored)}, false, nil
+		}
+	} else if sum, err = fileHash(path); err != nil {
+		return nil, false, err
+	}
+
+	var problems []string
+	if mf != nil {
+		switch {
+		case e.Type != manifest.File:
+			problems = append(problems, fmt.Sprintf("is a file, the manifest has a %s", e.Type))
+		case e.Size != size:
+			problems = append(problems, fmt.Sprintf("size is %d, the manifest has %d", size, e.Size))
+		case e.SHA256 != sum:
+			problems = append(problems, fmt.Sprintf("SHA-256 is %s, the manifest has %s", sum, e.SHA256))
+		}
+	}
+	if !strings.HasSuffix(name, sqlite.Ext) || len(problems) > 0 {
+		return problems, false, nil
+	}
+	// Databases copied as they are come with their write-ahead log.
+	wal, err := m.sidecarContent(path + "-wal")
+	if err != nil {
+		return nil, false, err
+	}
+	shm, err := m.sidecarContent(path + "-shm")
+	if err != nil {
+		return nil, false, err
+	}
+	dbProblems, err := checkDatabase(content, wal, shm)
+	if err != nil {
+		return nil, false, err
+	}
+	return append(problems, dbProblems...), true, nil
+}
+
+// Content of the backed up sidecar file of a database at path, in the LFS store if it is a pointer, or empty if the
+// database has none.
+func (m *Link) sidecarContent(path string) (string, error) {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	p, err := lfs.ReadPointerFile(path)
+	if err == lfs.ErrNotPointer {
+		return path, nil
+	} else if err != nil {
+		return "", err
+	}
+	if m.lfs == nil || !m.lfs.Store.Has(p.Oid) {
+		return "", errUnverified
+	}
+	return m.lfs.Store.Path(p.Oid), nil
+}
+
+// Run the SQLite integrity check on a copy of the database at path, as checking may write next to it. Its
+// write-ahead log and shared memory files, at wal and shm unless empty, are copied along so that the check reads the
+// database as SQLite would.
+func checkDatabase(path, wal, shm string) ([]string, error) {
+	tmpDir, err := os.MkdirTemp("", "notesforever-check-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	db := filepath.Join(tmpDir, "check"+sqlite.Ext)
+	for src, dst := range map[string]string{path: db, wal: db + "-wal", shm: db + "-shm"} {
+		if src == "" {
+			continue
+		}
+		if err := copyFile(src, dst); err != nil {
+			return nil, err
+		}
+	}
+	problems, err := sqlite.IntegrityCheck(db)
+	if err != nil {
+		// A database which SQLite cannot open is corrupt too.
+		return []string{errors.Cause(err).Error()}, nil
+	}
+	return problems, nil
+}